@@ -0,0 +1,173 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Series 带日期的价格序列, 日期需要严格递增
+type Series struct {
+	Dates  []time.Time
+	Values []float64
+}
+
+// AlignPolicy 组合与基准日期不一致或者存在NaN时的处理方式
+type AlignPolicy int
+
+const (
+	// AlignInner 只保留两个序列都有的日期, 存在NaN时返回错误
+	AlignInner AlignPolicy = iota
+	// AlignForwardFill 使用两个序列日期的并集, 缺失的日期以及NaN用前一个有效值填充,
+	// 开头无法填充的日期被丢弃
+	AlignForwardFill
+	// AlignDrop 只保留两个序列都有的日期, 并丢弃任一序列为NaN的日期
+	AlignDrop
+)
+
+// AlignReport 对齐的结果统计
+type AlignReport struct {
+	Points           int // 对齐后的观测个数
+	PortfolioFilled  int // 组合中被填充的观测个数
+	BenchFilled      int
+	PortfolioDropped int // 组合中被丢弃的观测个数, 包括NaN
+	BenchDropped     int
+}
+
+func (s Series) check(name string) error {
+	if len(s.Dates) != len(s.Values) {
+		return errors.New("In " + name + ", len(Dates) != len(Values)")
+	}
+	for i := 1; i < len(s.Dates); i++ {
+		if !s.Dates[i].After(s.Dates[i-1]) {
+			return fmt.Errorf("In %s, dates should be strictly increasing, see %s", name, s.Dates[i])
+		}
+	}
+	return nil
+}
+
+// alignRow 合并后的一个日期, 值不存在或为NaN时为NaN
+type alignRow struct {
+	date                   time.Time
+	portfolio, bench       float64
+	hasPortfolio, hasBench bool
+}
+
+// mergeSeries 按日期合并两个序列, 结果为日期的并集
+func mergeSeries(portfolio, bench Series) []alignRow {
+	var rows []alignRow
+	i, j := 0, 0
+	for i < len(portfolio.Dates) || j < len(bench.Dates) {
+		row := alignRow{portfolio: math.NaN(), bench: math.NaN()}
+		switch {
+		case j >= len(bench.Dates) || (i < len(portfolio.Dates) && portfolio.Dates[i].Before(bench.Dates[j])):
+			row.date = portfolio.Dates[i]
+		case i >= len(portfolio.Dates) || bench.Dates[j].Before(portfolio.Dates[i]):
+			row.date = bench.Dates[j]
+		default:
+			row.date = portfolio.Dates[i]
+		}
+		if i < len(portfolio.Dates) && portfolio.Dates[i].Equal(row.date) {
+			row.portfolio, row.hasPortfolio = portfolio.Values[i], true
+			i++
+		}
+		if j < len(bench.Dates) && bench.Dates[j].Equal(row.date) {
+			row.bench, row.hasBench = bench.Values[j], true
+			j++
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// AlignSeries 按policy对齐组合与基准, 返回对齐后的价格、日期以及统计
+// bench为空时只按policy处理组合中的NaN, 返回的b为nil, report中基准的个数为0
+func AlignSeries(portfolio, bench Series, policy AlignPolicy) (p, b []float64, dates []time.Time, report AlignReport, err error) {
+	if err := portfolio.check("AlignSeries, portfolio"); err != nil {
+		return nil, nil, nil, report, err
+	}
+	if err := bench.check("AlignSeries, bench"); err != nil {
+		return nil, nil, nil, report, err
+	}
+	rows := mergeSeries(portfolio, bench)
+	noBench := len(bench.Dates) == 0
+	if noBench {
+		for i := range rows {
+			rows[i].bench, rows[i].hasBench = 0, true
+		}
+	}
+	switch policy {
+	case AlignInner, AlignDrop:
+		for _, row := range rows {
+			if !row.hasPortfolio || !row.hasBench {
+				if row.hasPortfolio {
+					report.PortfolioDropped++
+				}
+				if row.hasBench && !noBench {
+					report.BenchDropped++
+				}
+				continue
+			}
+			if math.IsNaN(row.portfolio) || math.IsNaN(row.bench) {
+				if policy == AlignInner {
+					return nil, nil, nil, report, fmt.Errorf("In AlignSeries, NaN at %s", row.date)
+				}
+				report.PortfolioDropped++
+				if !noBench {
+					report.BenchDropped++
+				}
+				continue
+			}
+			p = append(p, row.portfolio)
+			b = append(b, row.bench)
+			dates = append(dates, row.date)
+		}
+	case AlignForwardFill:
+		lastPortfolio, lastBench := math.NaN(), math.NaN()
+		for _, row := range rows {
+			portfolioFilled := math.IsNaN(row.portfolio)
+			benchFilled := math.IsNaN(row.bench)
+			if !portfolioFilled {
+				lastPortfolio = row.portfolio
+			}
+			if !benchFilled {
+				lastBench = row.bench
+			}
+			if math.IsNaN(lastPortfolio) || math.IsNaN(lastBench) {
+				if row.hasPortfolio {
+					report.PortfolioDropped++
+				}
+				if row.hasBench && !noBench {
+					report.BenchDropped++
+				}
+				continue
+			}
+			if portfolioFilled {
+				report.PortfolioFilled++
+			}
+			if benchFilled {
+				report.BenchFilled++
+			}
+			p = append(p, lastPortfolio)
+			b = append(b, lastBench)
+			dates = append(dates, row.date)
+		}
+	default:
+		return nil, nil, nil, report, fmt.Errorf("In AlignSeries, unknown policy %d", policy)
+	}
+	report.Points = len(dates)
+	if noBench {
+		b = nil
+	}
+	return p, b, dates, report, nil
+}
+
+// NewAlignedMetricCalculator 用日期各自独立的组合与基准创建计算器, 按policy对齐并报告填充与丢弃的个数
+func NewAlignedMetricCalculator(portfolio, bench Series, policy AlignPolicy, config ...Config) (*MetricCalculator, AlignReport, error) {
+	p, b, dates, report, err := AlignSeries(portfolio, bench, policy)
+	if err != nil {
+		return nil, report, err
+	}
+	return NewMetricCalculator(p, b, dates, config...), report, nil
+}
@@ -0,0 +1,161 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// BootstrapMethod 重抽样的方式, 均按块抽取以保留收益率的自相关
+type BootstrapMethod int
+
+const (
+	// BootstrapStationary Politis-Romano平稳自助法, 块长服从均值为BlockSize的几何分布
+	BootstrapStationary BootstrapMethod = iota
+	// BootstrapBlock 块长固定为BlockSize的循环块自助法
+	BootstrapBlock
+)
+
+// BootstrapOptions ConfidenceInterval的可选参数
+type BootstrapOptions struct {
+	Method    BootstrapMethod
+	BlockSize int        // 平均或固定的块长, <= 0时取n^(1/3)
+	Rand      *rand.Rand // 随机数来源, nil时使用Seed创建, 相同的Seed得到相同的结果
+	Seed      int64
+	Params    Params // 指标参数, 与Process一致
+}
+
+// Interval 指标的点估计以及置信区间
+type Interval struct {
+	Estimate     float64
+	Lower, Upper float64
+	Level        float64
+	Resamples    int // 有效的重抽样次数, 出错或为NaN的样本被丢弃
+}
+
+// ConfidenceInterval 对组合与基准的收益率成对地做块自助重抽样, 以百分位数法给出指标在level下的置信区间
+// 任何已注册的指标都可以使用, 利率序列随收益率一同重抽样
+// 点估计与重抽样都在不含补上的0的收益率上计算, 由价格创建时可能与Process的结果略有不同
+func (c MetricCalculator) ConfidenceInterval(name string, level float64, resamples int, options ...BootstrapOptions) (Interval, error) {
+	interval := Interval{Estimate: math.NaN(), Lower: math.NaN(), Upper: math.NaN(), Level: level}
+	var opt BootstrapOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if level <= 0 || level >= 1 {
+		return interval, errors.New("In ConfidenceInterval, level should be in (0, 1)")
+	}
+	if resamples < 1 {
+		return interval, errors.New("In ConfidenceInterval, resamples should be positive")
+	}
+	if opt.Method != BootstrapStationary && opt.Method != BootstrapBlock {
+		return interval, fmt.Errorf("In ConfidenceInterval, unknown method %d", opt.Method)
+	}
+	var params []Params
+	if opt.Params != nil {
+		params = []Params{opt.Params}
+	}
+
+	// 由价格创建时第一个收益率是补上的0, 不参与点估计与重抽样
+	offset := 1
+	if c.fromReturns() {
+		offset = 0
+	}
+	pr := c.PortfolioRatio()
+	var br []float64
+	if c.benchLength() > 0 {
+		br = c.BenchRatio()
+	}
+	n := len(pr) - offset
+	if n < 2 {
+		return interval, errors.New("In ConfidenceInterval, not enough samples")
+	}
+	block := opt.BlockSize
+	if block <= 0 {
+		block = int(math.Max(1, math.Round(math.Cbrt(float64(n)))))
+	}
+	rng := opt.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(opt.Seed))
+	}
+
+	period := c.Period()
+	// sample 按下标从收益率创建计算器, 点估计使用原顺序的下标, 与重抽样的样本口径一致
+	sample := func(indices []int) MetricCalculator {
+		return MetricCalculator{
+			portfolioRet: resample(pr, indices, offset),
+			benchRet:     resample(br, indices, offset),
+			rf:           resample(c.rf, indices, offset),
+			mar:          resample(c.mar, indices, offset),
+			config:       c.config,
+			cache:        newCalculatorCache(),
+			period:       period,
+		}
+	}
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	estimate, err := sample(indices).Process(name, params...)
+	if err != nil {
+		return interval, err
+	}
+	interval.Estimate = estimate
+
+	values := make([]float64, 0, resamples)
+	for k := 0; k < resamples; k++ {
+		bootstrapIndices(rng, opt.Method, block, indices)
+		value, err := sample(indices).Process(name, params...)
+		if err != nil || math.IsNaN(value) {
+			continue
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return interval, errors.New("In ConfidenceInterval, every resample failed")
+	}
+	interval.Resamples = len(values)
+	interval.Lower = Quantile(values, (1-level)/2)
+	interval.Upper = Quantile(values, (1+level)/2)
+	return interval, nil
+}
+
+// bootstrapIndices 生成一组循环的块重抽样下标
+func bootstrapIndices(rng *rand.Rand, method BootstrapMethod, block int, indices []int) {
+	n := len(indices)
+	switch method {
+	case BootstrapBlock:
+		for i := 0; i < n; {
+			start := rng.Intn(n)
+			for j := 0; j < block && i < n; j++ {
+				indices[i] = (start + j) % n
+				i++
+			}
+		}
+	default:
+		p := 1 / float64(block)
+		current := rng.Intn(n)
+		for i := range indices {
+			if i > 0 {
+				if rng.Float64() < p {
+					current = rng.Intn(n)
+				} else {
+					current = (current + 1) % n
+				}
+			}
+			indices[i] = current
+		}
+	}
+}
+
+func resample(values []float64, indices []int, offset int) []float64 {
+	if values == nil {
+		return nil
+	}
+	result := make([]float64, len(indices))
+	for i, index := range indices {
+		result[i] = values[index+offset]
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package metric
+
+import (
+	"fmt"
+	"sync"
+)
+
+// cacheEntry 缓存项，done关闭之后value与err才可读
+type cacheEntry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// calculatorCache 计算器的中间结果缓存，可并发使用
+// 同一个key同时被多个goroutine请求时，只会计算一次，其余goroutine等待该结果
+type calculatorCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func newCalculatorCache() *calculatorCache {
+	return &calculatorCache{entries: map[string]*cacheEntry{}}
+}
+
+func (cache *calculatorCache) getOrSet(key string, process func() (interface{}, error)) (value interface{}, err error) {
+	cache.mu.Lock()
+	if entry, exist := cache.entries[key]; exist {
+		cache.mu.Unlock()
+		<-entry.done
+		return entry.value, entry.err
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	cache.entries[key] = entry
+	cache.mu.Unlock()
+
+	defer close(entry.done)
+	defer func() {
+		// process panic时把错误交给等待中的goroutine, 并移除该项使之后的调用重新计算
+		if r := recover(); r != nil {
+			entry.value, entry.err = nil, fmt.Errorf("In %s, panic: %v", key, r)
+			cache.mu.Lock()
+			delete(cache.entries, key)
+			cache.mu.Unlock()
+			value, err = entry.value, entry.err
+		}
+	}()
+	entry.value, entry.err = process()
+	return entry.value, entry.err
+}
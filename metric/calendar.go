@@ -0,0 +1,168 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/bxy09/gfstat/performance/utils"
+)
+
+// Frequency 观测的频率
+type Frequency int
+
+const (
+	FrequencyMinute Frequency = iota
+	FrequencyHourly
+	FrequencyDaily
+	FrequencyWeekly
+	FrequencyMonthly
+	FrequencyQuarterly
+	FrequencyAnnual
+)
+
+var frequencyNames = [...]string{"minute", "hourly", "daily", "weekly", "monthly", "quarterly", "annual"}
+
+func (f Frequency) String() string {
+	if f < 0 || int(f) >= len(frequencyNames) {
+		return "unknown"
+	}
+	return frequencyNames[f]
+}
+
+// Calendar 交易日历, 用于识别观测频率以及给出年化因子
+type Calendar interface {
+	Name() string
+	IsTradingDay(t time.Time) bool
+	// PeriodsPerYear 一年中该频率的期数, 即年化因子
+	PeriodsPerYear(freq Frequency) float64
+}
+
+// periodsPerYear 由每年的交易日数与每天的交易小时数得到各频率的年化因子
+func periodsPerYear(days, hours float64, freq Frequency) float64 {
+	switch freq {
+	case FrequencyMinute:
+		return days * hours * 60
+	case FrequencyHourly:
+		return days * hours
+	case FrequencyWeekly:
+		return 52
+	case FrequencyMonthly:
+		return 12
+	case FrequencyQuarterly:
+		return 4
+	case FrequencyAnnual:
+		return 1
+	default:
+		return days
+	}
+}
+
+// WeekdayCalendar 通用的日历, 周一至周五为交易日, 每年252个交易日, 每天交易6.5小时
+type WeekdayCalendar struct{}
+
+func (WeekdayCalendar) Name() string {
+	return "weekday"
+}
+
+func (WeekdayCalendar) IsTradingDay(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+func (WeekdayCalendar) PeriodsPerYear(freq Frequency) float64 {
+	return periodsPerYear(252, 6.5, freq)
+}
+
+var beijing = time.FixedZone("CST", 8*3600)
+
+// ChinaCalendar 上交所与深交所的近似交易日历, 按北京时间判断, 每年约242个交易日, 每天交易4小时
+// 只内置元旦(1月1日)、劳动节(5月1日至3日)与国庆节(10月1日至7日)的固定休市日, 实际安排以交易所每年的公告为准
+// 春节、清明、端午、中秋等农历节假日以及调整的休市日需要由调用者通过NewChinaCalendar提供,
+// 未提供时这些日期被当作交易日, 跨越长假的相邻两个观测可能不被识别为日频
+type ChinaCalendar struct {
+	holidays map[int64]bool
+}
+
+// NewChinaCalendar 创建沪深交易日历, holidays为额外的休市日, 例如交易所公告的春节休市日
+func NewChinaCalendar(holidays ...time.Time) *ChinaCalendar {
+	calendar := &ChinaCalendar{holidays: map[int64]bool{}}
+	for _, h := range holidays {
+		calendar.holidays[chinaDay(h)] = true
+	}
+	return calendar
+}
+
+func chinaDay(t time.Time) int64 {
+	return utils.DayInBJ(t)
+}
+
+func (*ChinaCalendar) Name() string {
+	return "SSE/SZSE"
+}
+
+func (c *ChinaCalendar) IsTradingDay(t time.Time) bool {
+	t = t.In(beijing)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	month, day := t.Month(), t.Day()
+	switch {
+	case month == time.January && day == 1:
+		return false
+	case month == time.May && day <= 3:
+		return false
+	case month == time.October && day <= 7:
+		return false
+	}
+	return !c.holidays[chinaDay(t)]
+}
+
+func (*ChinaCalendar) PeriodsPerYear(freq Frequency) float64 {
+	return periodsPerYear(242, 4, freq)
+}
+
+// tradingDaysBetween (from, to]之间的交易日数
+func tradingDaysBetween(calendar Calendar, from, to time.Time) int {
+	count := 0
+	for day := from.AddDate(0, 0, 1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if calendar.IsTradingDay(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// classifyGap 按相邻两个观测的间隔判断频率, 两个交易日之间的节假日不影响日频的判断
+func classifyGap(calendar Calendar, from, to time.Time) Frequency {
+	diff := to.Sub(from)
+	days := diff.Hours() / 24
+	switch {
+	case diff <= 10*time.Minute:
+		return FrequencyMinute
+	case diff < 20*time.Hour:
+		return FrequencyHourly
+	case days <= 16 && tradingDaysBetween(calendar, from, to) <= 1:
+		return FrequencyDaily
+	case days <= 16:
+		return FrequencyWeekly
+	case days <= 50:
+		return FrequencyMonthly
+	case days <= 135:
+		return FrequencyQuarterly
+	default:
+		return FrequencyAnnual
+	}
+}
+
+// DetectFrequency 取相邻观测间隔最常见的频率, 观测不足两个时视为日频
+func DetectFrequency(dates []time.Time, calendar Calendar) Frequency {
+	var counts [len(frequencyNames)]int
+	for i := 1; i < len(dates); i++ {
+		counts[classifyGap(calendar, dates[i-1], dates[i])]++
+	}
+	best := FrequencyDaily
+	for freq, count := range counts {
+		if count > counts[best] {
+			best = Frequency(freq)
+		}
+	}
+	return best
+}
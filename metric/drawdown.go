@@ -0,0 +1,100 @@
+package metric
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// DrawdownEpisode 一次回撤的完整记录, 从前一个高点开始到重新回到该高点为止
+// dates缺省时时间字段为零值, CalendarDays为0
+type DrawdownEpisode struct {
+	Peak, Trough, Recovery time.Time
+	PeakIndex              int
+	TroughIndex            int
+	RecoveryIndex          int // 未恢复时为-1
+	Recovered              bool
+	Depth                  float64 // 回撤深度, 以正数表示
+	Length                 int     // 从高点到恢复(未恢复时到序列末尾)的观测期数
+	ToTrough               int     // 从高点到谷底的观测期数
+	ToRecovery             int     // 从谷底到恢复的观测期数, 未恢复时为0
+	CalendarDays           float64 // 从高点到恢复(未恢复时到序列末尾)的自然日数
+}
+
+// findDrawdownEpisodes 按时间顺序找出所有回撤
+func findDrawdownEpisodes(drawdowns Vector, dates []time.Time) []DrawdownEpisode {
+	var episodes []DrawdownEpisode
+	var current *DrawdownEpisode
+	dateAt := func(i int) time.Time {
+		if dates == nil {
+			return time.Time{}
+		}
+		return dates[i]
+	}
+	finish := func(end int) {
+		current.Length = end - current.PeakIndex
+		current.ToTrough = current.TroughIndex - current.PeakIndex
+		if current.Recovered {
+			current.ToRecovery = current.RecoveryIndex - current.TroughIndex
+		}
+		if dates != nil {
+			current.CalendarDays = dates[end].Sub(current.Peak).Hours() / 24
+		}
+		episodes = append(episodes, *current)
+		current = nil
+	}
+	for i, dd := range drawdowns {
+		if dd < 0 {
+			if current == nil {
+				peak := i - 1
+				if peak < 0 {
+					peak = 0
+				}
+				current = &DrawdownEpisode{
+					Peak:          dateAt(peak),
+					PeakIndex:     peak,
+					RecoveryIndex: -1,
+				}
+			}
+			if -dd > current.Depth {
+				current.Depth = -dd
+				current.TroughIndex = i
+				current.Trough = dateAt(i)
+			}
+		} else if current != nil {
+			current.Recovered = true
+			current.RecoveryIndex = i
+			current.Recovery = dateAt(i)
+			finish(i)
+		}
+	}
+	if current != nil {
+		finish(len(drawdowns) - 1)
+	}
+	return episodes
+}
+
+// DrawdownEpisodes 按回撤深度从大到小排列的回撤记录, topN <= 0 时返回全部
+func DrawdownEpisodes(c MetricCalculator, topN int) ([]DrawdownEpisode, error) {
+	value, err := c.GetOrSet("PortfolioDrawdownEpisodes", func() (interface{}, error) {
+		if c.dates != nil && len(c.dates) != c.length() {
+			return []DrawdownEpisode(nil), errors.New("In DrawdownEpisodes, len(dates) != len(portfolio)")
+		}
+		drawdowns, err := PortfolioDrawDown(c)
+		if err != nil {
+			return []DrawdownEpisode(nil), err
+		}
+		return findDrawdownEpisodes(drawdowns, c.navDates()), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cached := value.([]DrawdownEpisode)
+	episodes := make([]DrawdownEpisode, len(cached))
+	copy(episodes, cached)
+	sort.SliceStable(episodes, func(i, j int) bool { return episodes[i].Depth > episodes[j].Depth })
+	if topN > 0 && topN < len(episodes) {
+		episodes = episodes[:topN]
+	}
+	return episodes, nil
+}
@@ -0,0 +1,105 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrorMode 批量计算时的错误处理方式
+type ErrorMode int
+
+const (
+	// CollectAll 计算全部指标，收集所有错误
+	CollectAll ErrorMode = iota
+	// FailFast 遇到第一个错误即停止
+	FailFast
+)
+
+// Result 单个指标的计算结果
+type Result struct {
+	Name     string
+	Value    float64
+	Err      error
+	Duration time.Duration
+}
+
+type Results []Result
+
+// Values 计算成功的指标值
+func (rs Results) Values() map[string]float64 {
+	values := make(map[string]float64, len(rs))
+	for _, r := range rs {
+		if r.Err == nil {
+			values[r.Name] = r.Value
+		}
+	}
+	return values
+}
+
+// Errors 计算失败的指标及其错误
+func (rs Results) Errors() map[string]error {
+	errs := map[string]error{}
+	for _, r := range rs {
+		if r.Err != nil {
+			errs[r.Name] = r.Err
+		}
+	}
+	return errs
+}
+
+// Get 按名称查找结果
+func (rs Results) Get(name string) (Result, bool) {
+	for _, r := range rs {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// MetricNames 所有已注册指标的名称，按字母序排列
+func MetricNames() []string {
+	names := make([]string, 0, len(MetricMap))
+	for name := range MetricMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EvaluateAll 计算MetricMap中所有指标
+func (c MetricCalculator) EvaluateAll(mode ErrorMode) (Results, error) {
+	return c.Evaluate(mode, MetricNames()...)
+}
+
+// Evaluate 按顺序计算指定的指标
+// FailFast模式下遇到错误立即返回已得到的结果与该错误;
+// CollectAll模式下计算全部指标，若有失败则返回汇总的错误
+func (c MetricCalculator) Evaluate(mode ErrorMode, names ...string) (Results, error) {
+	results := make(Results, 0, len(names))
+	var failed []string
+	for _, name := range names {
+		begin := time.Now()
+		value, err := c.Process(name)
+		results = append(results, Result{
+			Name:     name,
+			Value:    value,
+			Err:      err,
+			Duration: time.Since(begin),
+		})
+		if err == nil {
+			continue
+		}
+		if mode == FailFast {
+			return results, fmt.Errorf("In Evaluate, %s: %v", name, err)
+		}
+		failed = append(failed, name+": "+err.Error())
+	}
+	if len(failed) > 0 {
+		return results, errors.New("In Evaluate, failed metrics: " + strings.Join(failed, "; "))
+	}
+	return results, nil
+}
@@ -3,26 +3,70 @@ package metric
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"time"
 )
 
+// Config 计算器的参数配置，不同配置的计算结果互不干扰
+type Config struct {
+	Rf     float64 // 年化无风险利率, Rf=0.03
+	MAR    float64 // 年化最低可接受收益率, MAR=0.03
+	Scale  float64 // number of periods in a year (daily scale = 252, monthly scale = 12, quarterly scale = 4), <= 0时使用日历给出的年化因子
+	Method string  // 收益率计算方法, "discrete", "log"或"difference", 见MethodDiscrete
+	// Calendar 识别观测频率与年化所用的交易日历, nil时使用WeekdayCalendar
+	Calendar Calendar
+}
+
+// DefaultConfig 默认配置, 与performance包的默认参数一致
+func DefaultConfig() Config {
+	return Config{
+		Rf:     0.03,
+		MAR:    0.03,
+		Scale:  252.0,
+		Method: "discrete",
+	}
+}
+
+func (cfg Config) key() string {
+	return fmt.Sprintf("Rf=%g,MAR=%g,Scale=%g,Method=%s,Calendar=%s", cfg.Rf, cfg.MAR, cfg.Scale, cfg.Method, cfg.calendar().Name())
+}
+
+func (cfg Config) calendar() Calendar {
+	if cfg.Calendar == nil {
+		return WeekdayCalendar{}
+	}
+	return cfg.Calendar
+}
+
 type MetricCalculator struct {
 	portfolio, bench []float64
-	dates            []time.Time
-	vectorCache      map[string][]float64
-	scalarCache      map[string]float64
-	period           float64
-}
-
-func NewMetricCalculator(portfolio, bench []float64, dates []time.Time) *MetricCalculator {
-	return &MetricCalculator{
-		portfolio:   portfolio,
-		bench:       bench,
-		dates:       dates,
-		vectorCache: map[string][]float64{},
-		scalarCache: map[string]float64{},
+	// portfolioRet与benchRet为直接提供的收益率, 不为nil时portfolio与bench为空, 净值按需还原
+	portfolioRet, benchRet []float64
+	dates                  []time.Time
+	config                 Config
+	params                 Params
+	rf, mar                []float64 // 与portfolio对齐的年化利率序列, nil时使用Config中的Rf与MAR
+	cache                  *calculatorCache
+	period                 float64
+}
+
+// NewMetricCalculator 创建计算器，config缺省时使用DefaultConfig
+// 计算器可以被多个goroutine并发使用，每个中间结果只计算一次
+func NewMetricCalculator(portfolio, bench []float64, dates []time.Time, config ...Config) *MetricCalculator {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
 	}
+	calculator := &MetricCalculator{
+		portfolio: portfolio,
+		bench:     bench,
+		dates:     dates,
+		config:    cfg,
+		cache:     newCalculatorCache(),
+	}
+	calculator.resolveConfig()
+	return calculator
 }
 
 func NewDailyMetricCalculatorNoBench(portfolio []float64) *MetricCalculator {
@@ -31,113 +75,113 @@ func NewDailyMetricCalculatorNoBench(portfolio []float64) *MetricCalculator {
 	return calculator
 }
 
+// WithConfig 返回使用新配置的计算器，与原计算器共享数据与缓存
+func (m *MetricCalculator) WithConfig(config Config) *MetricCalculator {
+	calculator := *m
+	calculator.config = config
+	calculator.resolveConfig()
+	return &calculator
+}
+
+// resolveConfig 统一Method的名称, Scale <= 0时使用日历给出的年化因子, 未知的Method在计算指标时返回错误
+func (m *MetricCalculator) resolveConfig() {
+	m.config.Method = normalizeMethod(m.config.Method)
+	if m.config.Scale <= 0 {
+		m.config.Scale = m.Period()
+	}
+}
+
+// Frequency 按交易日历识别的观测频率
+func (m *MetricCalculator) Frequency() Frequency {
+	calendar := m.config.calendar()
+	frequency, _ := m.cache.getOrSet("Frequency|"+calendar.Name(), func() (interface{}, error) {
+		return DetectFrequency(m.dates, calendar), nil
+	})
+	return frequency.(Frequency)
+}
+
+func (m *MetricCalculator) Config() Config {
+	return m.config
+}
+
 func (m *MetricCalculator) Period() float64 {
 	if m.period > 0.01 {
 		return m.period
 	}
-	recordDate := m.dates
-	//to estimate the period
-	count_OneMinute := 0
-	count_OneDay := 0
-	count_OneMonth := 0
-	count_OneSeason := 0
-	for i := 1; i < len(recordDate); i++ {
-		diff := recordDate[i].Sub(recordDate[i-1])
-		//fmt.Println(diff.Hours(), diff.Minutes(), diff.Seconds())
-		if diff.Hours() >= 23.0 && diff.Hours() < 48.0 {
-			count_OneDay++
-		}
-		if diff.Minutes() >= 1.0 && diff.Minutes() <= 5.0 {
-			count_OneMinute++
-		}
-		if diff.Hours() >= 28.0*24.0 && diff.Hours() <= 35.0*24.0 {
-			count_OneMonth++
-		}
-		if diff.Hours() >= 3.8*30.0*24.0 && diff.Hours() <= 4.2*30.0*24.0 {
-			count_OneSeason++
-		}
-	}
-	//count the percent of the total length
-	len_Date := len(recordDate) - 1
-	Minute_Ratio := float64(count_OneMinute) / float64(len_Date)
-	Day_Ratio := float64(count_OneDay) / float64(len_Date)
-	Month_Ratio := float64(count_OneMonth) / float64(len_Date)
-	Season_Ratio := float64(count_OneSeason) / float64(len_Date)
-	if Minute_Ratio > Day_Ratio && Minute_Ratio > Month_Ratio && Minute_Ratio > Season_Ratio {
-		m.period = 2520.0
-		return m.period
-	}
-	if Day_Ratio > Minute_Ratio && Day_Ratio > Month_Ratio && Day_Ratio > Season_Ratio {
-		m.period = 252.0
-		return m.period
-	}
-	if Month_Ratio > Minute_Ratio && Month_Ratio > Day_Ratio && Month_Ratio > Season_Ratio {
-		m.period = 12.0
-		return m.period
-	}
-	if Season_Ratio > Minute_Ratio && Season_Ratio > Day_Ratio && Season_Ratio > Month_Ratio {
-		m.period = 4.0
-		return m.period
-	}
-	m.period = 252.0
-	return m.period
+	return m.config.calendar().PeriodsPerYear(m.Frequency())
 }
 
 func (c MetricCalculator) PortfolioRatio() []float64 {
-	key := "PortfolioRatio"
-	if c.vectorCache[key] != nil {
-		return c.vectorCache[key]
+	if c.fromReturns() {
+		return Vector(c.portfolioRet)
 	}
-	c.vectorCache[key] = Vector(c.portfolio).ReturnRatio("discrete")
-	return c.vectorCache[key]
+	ratio, _ := c.cache.getOrSet("PortfolioRatio|"+c.config.Method, func() (interface{}, error) {
+		return Vector(c.portfolio).ReturnRatio(c.config.Method), nil
+	})
+	return ratio.(Vector)
 }
 
 func (c MetricCalculator) BenchRatio() []float64 {
-	key := "BenchRatio"
-	if c.vectorCache[key] != nil {
-		return c.vectorCache[key]
+	if c.fromReturns() {
+		return Vector(c.benchRet)
 	}
-	c.vectorCache[key] = Vector(c.bench).ReturnRatio("discrete")
-	return c.vectorCache[key]
+	ratio, _ := c.cache.getOrSet("BenchRatio|"+c.config.Method, func() (interface{}, error) {
+		return Vector(c.bench).ReturnRatio(c.config.Method), nil
+	})
+	return ratio.(Vector)
+}
+
+// cacheKey 缓存键包含配置与参数，保证不同配置的结果不会混用
+func (c MetricCalculator) cacheKey(name string) string {
+	return name + "|" + c.config.key() + "|" + c.params.key() + c.seriesKey()
+}
+
+// GetOrSet 获取或计算任意类型的中间结果, 并发安全
+func (c MetricCalculator) GetOrSet(name string, process func() (interface{}, error)) (interface{}, error) {
+	return c.cache.getOrSet(c.cacheKey(name), process)
 }
 
 func (c MetricCalculator) GetOrSetScalar(name string, process func() (float64, error)) (float64, error) {
-	if value, exist := c.scalarCache[name]; exist {
-		return value, nil
+	value, err := c.GetOrSet(name, func() (interface{}, error) {
+		return process()
+	})
+	if scalar, ok := value.(float64); ok {
+		return scalar, err
 	}
-	value, err := process()
-	c.scalarCache[name] = value
-	return value, err
+	return math.NaN(), err
 }
 
 func (c MetricCalculator) GetOrSetVector(name string, process func() (Vector, error)) (Vector, error) {
-	if vector, exist := c.vectorCache[name]; exist {
-		return vector, nil
-	}
-	vector, err := process()
-	c.vectorCache[name] = vector
-	return vector, err
+	vector, err := c.GetOrSet(name, func() (interface{}, error) {
+		return process()
+	})
+	result, _ := vector.(Vector)
+	return result, err
 }
 
-func (c MetricCalculator) Process(name string) (float64, error) {
+// Process 计算指标, params可以覆盖Config中的参数或者指定指标自身的参数
+// 例如 Process("Kappa", Params{"l": 3, "MAR": 0.02})
+func (c MetricCalculator) Process(name string, params ...Params) (float64, error) {
 	metric, exist := MetricMap[name]
 	if !exist {
 		return math.NaN(), errors.New("No such metric")
 	}
+	if err := c.checkMethod(); err != nil {
+		return math.NaN(), err
+	}
+	c, err := c.withParams(name, params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if info, exist := MetricInfoMap[name]; exist {
+		if err := info.check(c); err != nil {
+			return math.NaN(), err
+		}
+	}
 	return metric(c)
 }
 
 type Metric func(c MetricCalculator) (float64, error)
 
+// MetricMap 所有已注册的指标, 新指标应通过Register注册以同时提供元数据
 var MetricMap = map[string]Metric{}
-
-//	Params:scale
-//		number of periods in a year (daily scale = Scale, monthly scale = 12, quarterly scale = 4)
-//	Params:MAR
-//		MAR=0.03
-//	Params:Rf
-//		Rf=0.03
-
-var MAR = 0.03 //---->scale 252 MAR=MAR/Scale
-var Rf = 0.03
-var Scale = 252.0
@@ -0,0 +1,52 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/bxy09/gfstat/performance/utils"
+)
+
+// NewIntradayMetricCalculator 按交易所的交易时段将分钟价格重采样为size周期的收盘价后创建计算器
+// 组合与基准按K线的名义结束时间对齐, bench为nil时没有基准
+// Period由config中日历的年交易日数与每天的K线个数得到, 未传入config时Scale取Period, 按K线周期年化
+func NewIntradayMetricCalculator(portfolio, bench []float64, dates []time.Time, exchange utils.Exchange, size utils.BarSize, config ...Config) (*MetricCalculator, error) {
+	cfg := DefaultConfig()
+	cfg.Scale = 0
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	bars, err := exchange.Resample(dates, portfolio, size)
+	if err != nil {
+		return nil, err
+	}
+	barDates, closes := utils.Closes(bars)
+	var benchCloses []float64
+	if bench != nil {
+		benchBars, err := exchange.Resample(dates, bench, size)
+		if err != nil {
+			return nil, err
+		}
+		benchDates, values := utils.Closes(benchBars)
+		closes, benchCloses, barDates, _, err = AlignSeries(Series{Dates: barDates, Values: closes}, Series{Dates: benchDates, Values: values}, AlignInner)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var period float64
+	switch {
+	case size >= utils.BarWeekly:
+		period = cfg.calendar().PeriodsPerYear(FrequencyWeekly)
+	default:
+		period = cfg.calendar().PeriodsPerYear(FrequencyDaily) * float64(exchange.BarsPerDay(size))
+	}
+	calculator := &MetricCalculator{
+		portfolio: closes,
+		bench:     benchCloses,
+		dates:     barDates,
+		config:    cfg,
+		cache:     newCalculatorCache(),
+		period:    period,
+	}
+	calculator.resolveConfig()
+	return calculator, nil
+}
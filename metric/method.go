@@ -0,0 +1,136 @@
+package metric
+
+import (
+	"errors"
+	"math"
+)
+
+// 收益率的计算方法, 通过Config.Method选择
+const (
+	// MethodDiscrete 简单收益率, p[i]/p[i-1]-1
+	MethodDiscrete = "discrete"
+	// MethodLog 对数收益率, log(p[i]/p[i-1]), 年化收益等指标仍以简单收益率表示
+	MethodLog = "log"
+	// MethodDifference 差分, p[i]-p[i-1], 用于以盈亏而非净值表示的序列
+	// 盈亏没有资本基数, 无风险收益与MAR视为0, 年化收益为每期均值*Scale
+	MethodDifference = "difference"
+)
+
+// methodAliases 与performance包Calculate兼容的名称
+var methodAliases = map[string]string{
+	"":           MethodDiscrete,
+	"simple":     MethodDiscrete,
+	"compound":   MethodLog,
+	"continuous": MethodLog,
+	"pnl":        MethodDifference,
+}
+
+func normalizeMethod(method string) string {
+	if alias, exist := methodAliases[method]; exist {
+		return alias
+	}
+	return method
+}
+
+// checkMethod 规范化之后的Method应为discrete、log或difference之一
+func (c MetricCalculator) checkMethod() error {
+	switch c.config.Method {
+	case MethodDiscrete, MethodLog, MethodDifference:
+		return nil
+	}
+	return errors.New("In Config, unknown method " + c.config.Method)
+}
+
+// periodRate 年化利率对应的每期收益, 与收益率的计算方法一致
+func (c MetricCalculator) periodRate(rate float64) float64 {
+	switch c.config.Method {
+	case MethodLog:
+		return math.Log1p(rate / c.Period())
+	case MethodDifference:
+		return 0
+	default:
+		return rate / c.Period()
+	}
+}
+
+// annualize 年化收益, discrete与log按复利计算
+func (c MetricCalculator) annualize(ratio Vector) float64 {
+	switch c.config.Method {
+	case MethodLog:
+		return math.Expm1(ratio.Average() * c.config.Scale)
+	case MethodDifference:
+		return ratio.Annualize(c.config.Scale, false)
+	default:
+		return ratio.Annualize(c.config.Scale, true)
+	}
+}
+
+// meanGeometric 每期的几何平均收益, difference为算术平均
+func (c MetricCalculator) meanGeometric(ratio Vector) float64 {
+	switch c.config.Method {
+	case MethodLog:
+		return math.Expm1(ratio.Average())
+	case MethodDifference:
+		return ratio.Average()
+	default:
+		sum := 0.0
+		for _, dr := range ratio {
+			sum += math.Log(dr + 1)
+		}
+		return math.Exp(sum/float64(len(ratio))) - 1
+	}
+}
+
+// change 价格由from变为to的收益, difference为两者之差, 其余为比值减1
+func (c MetricCalculator) change(from, to float64) float64 {
+	if c.config.Method == MethodDifference {
+		return to - from
+	}
+	return to/from - 1
+}
+
+// drawdowns 净值相对此前最高点的回撤, difference的净值可以从0开始或者为负, 按差值计算
+func (c MetricCalculator) drawdowns(nav Vector) Vector {
+	if c.config.Method != MethodDifference {
+		return nav.Drawdowns()
+	}
+	if len(nav) < 1 {
+		return nil
+	}
+	result := make(Vector, len(nav))
+	curMax := nav[0]
+	for i, r := range nav {
+		if r > curMax {
+			curMax = r
+		} else {
+			result[i] = r - curMax
+		}
+	}
+	return result
+}
+
+// Prices 由收益率还原价格序列, 结果比收益率多一个起点
+// discrete与log的起点为1, difference的起点为0
+func (v1 Vector) Prices(method string) Vector {
+	method = normalizeMethod(method)
+	prices := make(Vector, len(v1)+1)
+	switch method {
+	case MethodDiscrete:
+		prices[0] = 1
+		for i, r := range v1 {
+			prices[i+1] = prices[i] * (1 + r)
+		}
+	case MethodLog:
+		prices[0] = 1
+		for i, r := range v1 {
+			prices[i+1] = prices[i] * math.Exp(r)
+		}
+	case MethodDifference:
+		for i, r := range v1 {
+			prices[i+1] = prices[i] + r
+		}
+	default:
+		return nil
+	}
+	return prices
+}
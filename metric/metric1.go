@@ -6,45 +6,356 @@ import (
 )
 
 func init() {
-	MetricMap["Annualized"] = PortfolioAnnualize
-	MetricMap["MeanGeometric"] = MeanGeometric
-	MetricMap["Variance"] = PortfolioVariance
-	MetricMap["StdDev"] = PortfolioStdDev
-	MetricMap["StdDev_Annualized"] = PortfolioStdDevAnnualized
-	MetricMap["SharpeRatio"] = PortfolioSharpeRatio
-	MetricMap["Skewness"] = PortfolioSkewness
-	MetricMap["Kurtosis"] = PortfolioKurtosis
-	MetricMap["AdjustedSharpeRatio"] = PortfolioAdjustedSharpeRatio
-	MetricMap["MaxDrawdown"] = PortfolioMaxDrawDown
-	MetricMap["AverageDrawdown"] = PortfolioAverageDrawDown
-	MetricMap["AverageLength"] = PortfolioAverageLength
-	MetricMap["AverageRecovery"] = PortfolioAverageRecovery
-	MetricMap["UpsideFrequency"] = PortfolioUpsideFrequency
-	MetricMap["DownsideDeviation2"] = PortfolioDownsideDeviation
-	MetricMap["SortinoRatio"] = PortfolioSortinoRatio
-	MetricMap["ProspectRatio"] = PortfolioProspectRatio
-	MetricMap["UpsidePotentialRatio"] = PortfolioUpsidePotentialRatio
-	MetricMap["UpsideRisk"] = PortfolioUpsideRisk
-	MetricMap["KellyRatio_Full"] = PortfolioKellyRatioFull
-	MetricMap["DRatio"] = PortfolioDRatio
-	MetricMap["BernardoLedoitRatio"] = PortfolioBernardoLedoitRatio
-	MetricMap["CalmarRatio"] = PortfolioCalmarRatio
-	MetricMap["SterlingRatio"] = PortfolioSterlingRatio
-	MetricMap["PainIndex"] = PortfolioPainIndex
-	MetricMap["PainRatio"] = PortfolioPainRatio
-	MetricMap["Kappa"] = PortfolioKappa
-	MetricMap["BurkeRatio"] = PortfolioBurkeRatio
+	Register(MetricInfo{
+		Name:           "Annualized",
+		DisplayName:    "Annualized Return",
+		Description:    "年化收益率",
+		Category:       CategoryReturn,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamScale},
+	}, PortfolioAnnualize)
+	Register(MetricInfo{
+		Name:           "MeanGeometric",
+		DisplayName:    "Geometric Mean",
+		Description:    "收益率序列的几何均值，非年化",
+		Category:       CategoryReturn,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     2,
+	}, MeanGeometric)
+	Register(MetricInfo{
+		Name:           "Variance",
+		DisplayName:    "Variance",
+		Description:    "收益率方差",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     3,
+	}, PortfolioVariance)
+	Register(MetricInfo{
+		Name:           "StdDev",
+		DisplayName:    "Standard Deviation",
+		Description:    "收益率标准差",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     3,
+	}, PortfolioStdDev)
+	Register(MetricInfo{
+		Name:           "StdDev_Annualized",
+		DisplayName:    "Annualized Standard Deviation",
+		Description:    "年化标准差",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     3,
+		Params:         []string{ParamScale},
+	}, PortfolioStdDevAnnualized)
+	Register(MetricInfo{
+		Name:           "SharpeRatio",
+		DisplayName:    "Sharpe Ratio",
+		Description:    "年化超额收益与年化标准差之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, PortfolioSharpeRatio)
+	Register(MetricInfo{
+		Name:           "Skewness",
+		DisplayName:    "Skewness",
+		Description:    "收益率偏度",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     3,
+	}, PortfolioSkewness)
+	Register(MetricInfo{
+		Name:           "Kurtosis",
+		DisplayName:    "Kurtosis",
+		Description:    "收益率超额峰度",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: false,
+		MinSamples:     4,
+	}, PortfolioKurtosis)
+	Register(MetricInfo{
+		Name:           "AdjustedSharpeRatio",
+		DisplayName:    "Adjusted Sharpe Ratio",
+		Description:    "经偏度峰度调整的夏普比率",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     4,
+		Params:         []string{ParamRf, ParamScale},
+	}, PortfolioAdjustedSharpeRatio)
+	Register(MetricInfo{
+		Name:           "MaxDrawdown",
+		DisplayName:    "Maximum Drawdown",
+		Description:    "最大回撤，以正数表示",
+		Category:       CategoryDrawdown,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioMaxDrawDown)
+	Register(MetricInfo{
+		Name:           "AverageDrawdown",
+		DisplayName:    "Average Drawdown",
+		Description:    "平均回撤深度",
+		Category:       CategoryDrawdown,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioAverageDrawDown)
+	Register(MetricInfo{
+		Name:           "AverageLength",
+		DisplayName:    "Average Drawdown Length",
+		Description:    "平均回撤持续期数",
+		Category:       CategoryDrawdown,
+		Unit:           UnitPeriods,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioAverageLength)
+	Register(MetricInfo{
+		Name:           "AverageRecovery",
+		DisplayName:    "Average Recovery",
+		Description:    "从谷底恢复的平均期数",
+		Category:       CategoryDrawdown,
+		Unit:           UnitPeriods,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioAverageRecovery)
+	Register(MetricInfo{
+		Name:           "UpsideFrequency",
+		DisplayName:    "Upside Frequency",
+		Description:    "收益率超过MAR的频率",
+		Category:       CategoryReturn,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioUpsideFrequency)
+	Register(MetricInfo{
+		Name:           "DownsideDeviation2",
+		DisplayName:    "Downside Deviation",
+		Description:    "低于MAR部分的下行标准差",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioDownsideDeviation)
+	Register(MetricInfo{
+		Name:           "SortinoRatio",
+		DisplayName:    "Sortino Ratio",
+		Description:    "超MAR平均收益与下行标准差之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioSortinoRatio)
+	Register(MetricInfo{
+		Name:           "ProspectRatio",
+		DisplayName:    "Prospect Ratio",
+		Description:    "给损失赋予更大权重的调整收益率",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioProspectRatio)
+	Register(MetricInfo{
+		Name:           "UpsidePotentialRatio",
+		DisplayName:    "Upside Potential Ratio",
+		Description:    "超MAR部分的平均收益与下行标准差之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioUpsidePotentialRatio)
+	Register(MetricInfo{
+		Name:           "UpsideRisk",
+		DisplayName:    "Upside Risk",
+		Description:    "超过MAR部分的上行标准差",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+		Options:        []ParamSpec{upsideRiskStat},
+	}, PortfolioUpsideRisk)
+	Register(MetricInfo{
+		Name:           "KellyRatio_Full",
+		DisplayName:    "Kelly Ratio",
+		Description:    "平均超额收益除以方差",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     3,
+		Params:         []string{ParamRf},
+	}, PortfolioKellyRatioFull)
+	Register(MetricInfo{
+		Name:           "DRatio",
+		DisplayName:    "D Ratio",
+		Description:    "考虑正负收益频率的负收益/正收益之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioDRatio)
+	Register(MetricInfo{
+		Name:           "BernardoLedoitRatio",
+		DisplayName:    "Bernardo Ledoit Ratio",
+		Description:    "正收益率之和/负收益率之和",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+	}, PortfolioBernardoLedoitRatio)
+	Register(MetricInfo{
+		Name:           "CalmarRatio",
+		DisplayName:    "Calmar Ratio",
+		Description:    "年化收益与最大回撤之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamScale},
+	}, PortfolioCalmarRatio)
+	Register(MetricInfo{
+		Name:           "SterlingRatio",
+		DisplayName:    "Sterling Ratio",
+		Description:    "年化收益与(最大回撤+excess)之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamScale},
+		Options:        []ParamSpec{sterlingExcess},
+	}, PortfolioSterlingRatio)
+	Register(MetricInfo{
+		Name:           "PainIndex",
+		DisplayName:    "Pain Index",
+		Description:    "回撤绝对值的平均",
+		Category:       CategoryDrawdown,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioPainIndex)
+	Register(MetricInfo{
+		Name:           "PainRatio",
+		DisplayName:    "Pain Ratio",
+		Description:    "年化超额收益与PainIndex之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamRf, ParamScale},
+	}, PortfolioPainRatio)
+	Register(MetricInfo{
+		Name:           "Kappa",
+		DisplayName:    "Kappa",
+		Description:    "超MAR平均收益除以低于MAR部分的l阶矩的l次根",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+		Options:        []ParamSpec{kappaOrder},
+	}, PortfolioKappa)
+	Register(MetricInfo{
+		Name:           "BurkeRatio",
+		DisplayName:    "Burke Ratio",
+		Description:    "经回撤平方和调整的年化超额收益",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamRf, ParamScale},
+	}, PortfolioBurkeRatio)
+	Register(MetricInfo{
+		Name:           "SharpeRatio_Annualized",
+		DisplayName:    "Annualized Sharpe Ratio",
+		Description:    "年化超额收益与年化标准差之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, PortfolioSharpeRatio)
+	Register(MetricInfo{
+		Name:           "MeanAbsoluteDeviation",
+		DisplayName:    "Mean Absolute Deviation",
+		Description:    "收益率偏离均值的平均绝对值",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+	}, PortfolioMeanAbsoluteDeviation)
+	Register(MetricInfo{
+		Name:           "SkewnessKurtosisRatio",
+		DisplayName:    "Skewness Kurtosis Ratio",
+		Description:    "偏度与峰度之比",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     4,
+	}, PortfolioSkewnessKurtosisRatio)
+	Register(MetricInfo{
+		Name:           "DownsideFrequency2",
+		DisplayName:    "Downside Frequency",
+		Description:    "收益率低于MAR的频率",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioDownsideFrequency)
+	Register(MetricInfo{
+		Name:           "KellyRatio_Half",
+		DisplayName:    "Half Kelly Ratio",
+		Description:    "Kelly比率的一半",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     3,
+		Params:         []string{ParamRf},
+	}, PortfolioKellyRatioHalf)
+	Register(MetricInfo{
+		Name:           "VolatilitySkewness_Variance",
+		DisplayName:    "Volatility Skewness",
+		Description:    "上行方差与下行方差之比",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioVolatilitySkewness)
+	Register(MetricInfo{
+		Name:           "VolatilitySkewness_Risk",
+		DisplayName:    "Variability Skewness",
+		Description:    "上行标准差与下行标准差之比",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     1,
+		Params:         []string{ParamMAR},
+	}, PortfolioVariabilitySkewness)
 }
 
 func PortfolioAnnualize(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioAnnualized", func() (float64, error) {
-		return Vector(c.PortfolioRatio()).Annualize(Scale, true), nil
+		return c.annualize(c.PortfolioRatio()), nil
 	})
 }
 
 func BenchAnnualize(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("BenchAnnualize", func() (float64, error) {
-		return Vector(c.BenchRatio()).Annualize(Scale, true), nil
+		return c.annualize(c.BenchRatio()), nil
 	})
 }
 
@@ -54,11 +365,7 @@ func MeanGeometric(c MetricCalculator) (float64, error) {
 		if len(pr) <= 0 {
 			return math.NaN(), errors.New("In MeanGeometric, Ra.Count() <= 0")
 		}
-		sum := 0.0
-		for _, dr := range pr {
-			sum += math.Log(dr + 1)
-		}
-		return math.Exp(sum/float64(len(pr))) - 1, nil
+		return c.meanGeometric(pr), nil
 	})
 }
 
@@ -105,7 +412,7 @@ func PortfolioStdDevAnnualized(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		return math.Sqrt(float64(Scale)) * value, nil
+		return math.Sqrt(float64(c.config.Scale)) * value, nil
 	})
 }
 
@@ -115,17 +422,16 @@ func PortfolioSharpeRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		periodRf := Rf / c.Period()
-		pr := c.PortfolioRatio()
+		pr, periodRf := c.rfExcess("Portfolio", c.PortfolioRatio())
 		length := len(pr)
 		if length == 0 {
 			return math.NaN(), errors.New("In SharpeRatio, data lenght == 0")
 		}
-		prod := 1.0
-		for _, p := range pr {
-			prod *= (p - periodRf) + 1
+		excess := make(Vector, length)
+		for i, p := range pr {
+			excess[i] = p - periodRf
 		}
-		numerator := math.Pow(prod, float64(Scale)/float64(length)) - 1.0
+		numerator := c.annualize(excess)
 		return numerator / denominator, nil
 	})
 }
@@ -195,7 +501,7 @@ func PortfolioAdjustedSharpeRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		SR := (Rp - Rf) / Sigp
+		SR := (Rp - c.annualRf()) / Sigp
 		K, err := PortfolioKurtosis(c)
 		if err != nil {
 			return math.NaN(), err
@@ -211,7 +517,7 @@ func PortfolioAdjustedSharpeRatio(c MetricCalculator) (float64, error) {
 
 func PortfolioDrawDown(c MetricCalculator) (Vector, error) {
 	return c.GetOrSetVector("PortfolioDrawDown", func() (Vector, error) {
-		return Vector(c.portfolio).Drawdowns(), nil
+		return c.drawdowns(c.PortfolioNAV()), nil
 	})
 }
 
@@ -230,43 +536,22 @@ func PortfolioMaxDrawDown(c MetricCalculator) (float64, error) {
 				max = dd
 			}
 		}
-		return -max, nil
+		// 单调上涨时max为0, 避免返回-0使CalmarRatio等得到-Inf
+		return 0 - max, nil
 	})
 }
 
 func PortfolioAnalysisDrawDown(c MetricCalculator) (draw, length, recovery Vector, err error) {
-	const (
-		PDraws    = "PortfolioDraw"
-		PLength   = "PortfolioDrawLength"
-		PRecovery = "PortfolioDrawVector"
-	)
-	var exist bool
-	draw, exist = c.vectorCache[PDraws]
-	if !exist {
-		var drawdowns Vector
-		drawdowns, err = PortfolioDrawDown(c)
+	value, err := c.GetOrSet("PortfolioAnalysisDrawDown", func() (interface{}, error) {
+		drawdowns, err := PortfolioDrawDown(c)
 		if err != nil {
-			return
+			return [3]Vector{}, err
 		}
-		draw, length, recovery, err = AnalysisDrawDown(drawdowns)
-		if err != nil {
-			c.vectorCache[PDraws] = draw
-			c.vectorCache[PLength] = length
-			c.vectorCache[PRecovery] = recovery
-		}
-		return
-	}
-	length, exist = c.vectorCache[PDraws]
-	if !exist {
-		err = errors.New(PDraws + " absent, internal error!")
-		return
-	}
-	recovery = c.vectorCache[PRecovery]
-	if !exist {
-		err = errors.New(PRecovery + " absent, internal error!")
-		return
-	}
-	return
+		draw, length, recovery, err := AnalysisDrawDown(drawdowns)
+		return [3]Vector{draw, length, recovery}, err
+	})
+	analysis, _ := value.([3]Vector)
+	return analysis[0], analysis[1], analysis[2], err
 }
 
 func AnalysisDrawDown(drawdowns Vector) (draw, length, recovery Vector, err error) {
@@ -337,12 +622,11 @@ func AnalysisDrawDown(drawdowns Vector) (draw, length, recovery Vector, err erro
 
 func PortfolioUpsideFrequency(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioUpsideFrequency", func() (float64, error) {
-		vector := c.PortfolioRatio()
+		vector, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
 		if len(vector) < 1 {
 			return math.NaN(), nil
 		}
 		above := 0
-		periodMAR := MAR / c.Period()
 		for _, value := range vector {
 			if value > periodMAR {
 				above++
@@ -437,7 +721,7 @@ func DownsideDeviation(array []float64, MAR float64) (float64, error) {
 
 func PortfolioDownsideDeviation(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioDownsideDeviation", func() (float64, error) {
-		return DownsideDeviation(c.PortfolioRatio(), MAR/c.Period())
+		return DownsideDeviation(c.marExcess("Portfolio", c.PortfolioRatio()))
 	})
 }
 
@@ -447,7 +731,8 @@ func PortfolioSortinoRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		return (Vector(c.PortfolioRatio()).Average() - MAR/c.Period()) / dd, nil
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
+		return (Vector(pr).Average() - periodMAR) / dd, nil
 	})
 }
 
@@ -457,26 +742,28 @@ func PortfolioProspectRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		var posSum, negSum float64
+		var posSum, negSum, marSum float64
 		pr := c.PortfolioRatio()
-		for _, value := range pr {
+		excess, periodMAR := c.marExcess("Portfolio", pr)
+		for i, value := range pr {
 			if value > 0 {
 				posSum += value
 			} else {
 				negSum += value
 			}
+			// 有MAR序列时按当期的MAR扣除
+			marSum += value - excess[i]
 		}
 
-		return ((posSum+2.25*negSum)/float64(len(pr)) - MAR/c.Period()) / dd, nil
+		return ((posSum+2.25*negSum-marSum)/float64(len(pr)) - periodMAR) / dd, nil
 	})
 }
 
 func PortfolioUpsidePotentialRatio(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioUpsidePotentialRatio", func() (float64, error) {
-		pr := c.PortfolioRatio()
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
 		sum := 0.0
 		upsideCount := 0
-		periodMAR := MAR / c.Period()
 		for _, value := range pr {
 			if value > periodMAR {
 				upsideCount++
@@ -498,26 +785,51 @@ func PortfolioUpsidePotentialRatio(c MetricCalculator) (float64, error) {
 	})
 }
 
-func PortfolioUpsideRisk(c MetricCalculator) (float64, error) {
-	return c.GetOrSetScalar("PortfolioUpsideRisk", func() (float64, error) {
-		pr := c.PortfolioRatio()
-		sum := 0.0
-		upsideCount := 0
-		periodMAR := MAR / c.Period()
-		for _, value := range pr {
-			if value > periodMAR {
-				upsideCount++
-				diff := value - periodMAR
+var upsideRiskStat = ParamSpec{
+	Name:        "stat",
+	Description: "risk为上行标准差, variance为上行方差, potential为上行潜力",
+	Default:     "risk",
+	Choices:     []string{"risk", "variance", "potential"},
+}
+
+// UpsideRisk 超过MAR部分的上行风险, 只统计超过MAR的子集, 没有超过MAR的收益率时为0
+func UpsideRisk(array []float64, MAR float64, stat string) (float64, error) {
+	sum := 0.0
+	upsideCount := 0
+	for _, value := range array {
+		if value > MAR {
+			upsideCount++
+			diff := value - MAR
+			if stat == "potential" {
+				sum += diff
+			} else {
 				sum += diff * diff
 			}
 		}
-		isSubset := true
-		if isSubset {
-			sum = sum / float64(upsideCount)
-		} else {
-			sum = sum / float64(len(pr))
-		}
+	}
+	if upsideCount == 0 {
+		return 0, nil
+	}
+	isSubset := true
+	if isSubset {
+		sum = sum / float64(upsideCount)
+	} else {
+		sum = sum / float64(len(array))
+	}
+	switch stat {
+	case "risk":
 		return math.Sqrt(sum), nil
+	case "variance", "potential":
+		return sum, nil
+	default:
+		return math.NaN(), errors.New("In UpsideRisk, unknown stat " + stat)
+	}
+}
+
+func PortfolioUpsideRisk(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioUpsideRisk", func() (float64, error) {
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
+		return UpsideRisk(pr, periodMAR, c.StringParam(upsideRiskStat))
 	})
 }
 func PortfolioKellyRatioFull(c MetricCalculator) (float64, error) {
@@ -526,8 +838,8 @@ func PortfolioKellyRatioFull(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		pr := c.PortfolioRatio()
-		return (Vector(pr).Average() - Rf/c.Period()) / varData, nil
+		pr, periodRf := c.rfExcess("Portfolio", c.PortfolioRatio())
+		return (Vector(pr).Average() - periodRf) / varData, nil
 	})
 }
 
@@ -579,9 +891,17 @@ func PortfolioCalmarRatio(c MetricCalculator) (float64, error) {
 	})
 }
 
+var sterlingExcess = ParamSpec{
+	Name:        "excess",
+	Description: "加在最大回撤上的超额部分",
+	Default:     0.1,
+	Min:         0,
+	Max:         1,
+}
+
 func PortfolioSterlingRatio(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioSterlingRatio", func() (float64, error) {
-		excess := 0.1
+		excess := c.FloatParam(sterlingExcess)
 		ar, err := PortfolioAnnualize(c)
 		if err != nil {
 			return math.NaN(), err
@@ -625,7 +945,7 @@ func PortfolioPainRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		return (a - Rf) / pi, nil
+		return (a - c.annualRf()) / pi, nil
 	})
 }
 
@@ -642,9 +962,18 @@ func Kappa(rRatio []float64, MAR float64, l float64) float64 {
 	return (m - MAR) / math.Pow(temp, 1.0/float64(l))
 }
 
+var kappaOrder = ParamSpec{
+	Name:        "l",
+	Description: "低于MAR部分矩的阶数",
+	Default:     1.0,
+	Min:         1,
+	Max:         100,
+}
+
 func PortfolioKappa(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("PortfolioKappa", func() (float64, error) {
-		return Kappa(c.PortfolioRatio(), MAR/c.Period(), 1.0), nil
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
+		return Kappa(pr, periodMAR, c.FloatParam(kappaOrder)), nil
 	})
 }
 func PortfolioBurkeRatio(c MetricCalculator) (float64, error) {
@@ -653,11 +982,12 @@ func PortfolioBurkeRatio(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		denominator := ra - Rf
+		denominator := ra - c.annualRf()
 		peak := 0
-		portfolio := c.portfolio
+		portfolio := c.PortfolioNAV()
 		inDrawDown := false
 		sum := 0.0
+		count := 0
 		for i := 1; i < len(portfolio); i++ {
 			if portfolio[i] < portfolio[i-1] {
 				if !inDrawDown {
@@ -666,17 +996,23 @@ func PortfolioBurkeRatio(c MetricCalculator) (float64, error) {
 				}
 			} else {
 				if inDrawDown {
-					draw := portfolio[i-1]/portfolio[peak] - 1.0
+					draw := c.change(portfolio[peak], portfolio[i-1])
 					sum += draw * draw
+					count++
 					inDrawDown = false
 				}
 			}
 		}
 		if inDrawDown {
-			draw := portfolio[len(portfolio)-1]/portfolio[peak] - 1.0
+			draw := c.change(portfolio[peak], portfolio[len(portfolio)-1])
 			sum += draw * draw
+			count++
 			inDrawDown = false
 		}
+		// 没有回撤时为0
+		if count == 0 {
+			return 0, nil
+		}
 		modified := true
 		if modified {
 			sum /= float64(len(portfolio))
@@ -685,3 +1021,92 @@ func PortfolioBurkeRatio(c MetricCalculator) (float64, error) {
 
 	})
 }
+
+func PortfolioMeanAbsoluteDeviation(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioMeanAbsoluteDeviation", func() (float64, error) {
+		pr := c.PortfolioRatio()
+		if len(pr) <= 0 {
+			return math.NaN(), errors.New("In MeanAbsoluteDeviation, Ra.Count() <= 0")
+		}
+		mean := Vector(pr).Average()
+		sum := 0.0
+		for _, value := range pr {
+			sum += math.Abs(value - mean)
+		}
+		return sum / float64(len(pr)), nil
+	})
+}
+
+func PortfolioSkewnessKurtosisRatio(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioSkewnessKurtosisRatio", func() (float64, error) {
+		ske, err := PortfolioSkewness(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		kur, err := PortfolioKurtosis(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return ske / kur, nil
+	})
+}
+
+// DownsideFrequency 收益率低于MAR的频率
+func DownsideFrequency(array []float64, MAR float64) (float64, error) {
+	if len(array) <= 0 {
+		return math.NaN(), errors.New("In DownsideFrequency, Ra.Count() <= 0")
+	}
+	below := 0
+	for _, value := range array {
+		if value < MAR {
+			below++
+		}
+	}
+	return float64(below) / float64(len(array)), nil
+}
+
+func PortfolioDownsideFrequency(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioDownsideFrequency", func() (float64, error) {
+		return DownsideFrequency(c.marExcess("Portfolio", c.PortfolioRatio()))
+	})
+}
+
+func PortfolioKellyRatioHalf(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioKellyRatioHalf", func() (float64, error) {
+		full, err := PortfolioKellyRatioFull(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return full / 2, nil
+	})
+}
+
+func PortfolioVolatilitySkewness(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioVolatilitySkewness", func() (float64, error) {
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
+		usr, err := UpsideRisk(pr, periodMAR, "variance")
+		if err != nil {
+			return math.NaN(), err
+		}
+		dd, err := PortfolioDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return usr / (dd * dd), nil
+	})
+}
+
+func PortfolioVariabilitySkewness(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioVariabilitySkewness", func() (float64, error) {
+		pr, periodMAR := c.marExcess("Portfolio", c.PortfolioRatio())
+		usr, err := UpsideRisk(pr, periodMAR, "risk")
+		if err != nil {
+			return math.NaN(), err
+		}
+		dd, err := PortfolioDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return usr / dd, nil
+	})
+}
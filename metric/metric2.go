@@ -7,17 +7,193 @@ import (
 )
 
 func init() {
-	MetricMap["ActivePremium"] = ActivePremium
-	MetricMap["TrackingError"] = TrackingError
-	MetricMap["InformationRatio"] = InformationRatio
-	MetricMap["MSquared"] = MSquared
-	MetricMap["JensenAlpha2"] = JensenAlpha
-	MetricMap["TreynorRatio"] = TreynorRatio
-	MetricMap["AppraisalRatio"] = AppraisalRatio
-	MetricMap["SpecificRisk"] = SpecificRisk
-	MetricMap["SystematicRisk"] = SystematicRisk
-	MetricMap["TotalRisk"] = TotalRisk
-	MetricMap["Beta"] = Beta
+	Register(MetricInfo{
+		Name:           "ActivePremium",
+		DisplayName:    "Active Premium",
+		Description:    "年化收益率与基准年化收益率之差",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     2,
+		Params:         []string{ParamScale},
+	}, ActivePremium)
+	Register(MetricInfo{
+		Name:           "TrackingError",
+		DisplayName:    "Tracking Error",
+		Description:    "超基准收益率的年化标准差",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamScale},
+	}, TrackingError)
+	Register(MetricInfo{
+		Name:           "InformationRatio",
+		DisplayName:    "Information Ratio",
+		Description:    "ActivePremium与TrackingError之比",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamScale},
+	}, InformationRatio)
+	Register(MetricInfo{
+		Name:           "MSquared",
+		DisplayName:    "M Squared",
+		Description:    "调整至基准风险水平的年化收益率",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, MSquared)
+	Register(MetricInfo{
+		Name:           "JensenAlpha2",
+		DisplayName:    "Jensen's Alpha",
+		Description:    "经系统风险调整的年化超额收益",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, JensenAlpha)
+	Register(MetricInfo{
+		Name:           "TreynorRatio",
+		DisplayName:    "Treynor Ratio",
+		Description:    "年化超额收益与beta之比",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, TreynorRatio)
+	Register(MetricInfo{
+		Name:           "AppraisalRatio",
+		DisplayName:    "Appraisal Ratio",
+		Description:    "Jensen's alpha与beta之比",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, AppraisalRatio)
+	Register(MetricInfo{
+		Name:           "SpecificRisk",
+		DisplayName:    "Specific Risk",
+		Description:    "回归残差的年化标准差",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamScale},
+	}, SpecificRisk)
+	Register(MetricInfo{
+		Name:           "SystematicRisk",
+		DisplayName:    "Systematic Risk",
+		Description:    "beta与基准年化标准差之积",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, SystematicRisk)
+	Register(MetricInfo{
+		Name:           "TotalRisk",
+		DisplayName:    "Total Risk",
+		Description:    "系统风险与特质风险的平方和开方",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, TotalRisk)
+	Register(MetricInfo{
+		Name:           "Beta",
+		DisplayName:    "Beta",
+		Description:    "组合收益率对基准收益率的回归斜率",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+	}, Beta)
+	Register(MetricInfo{
+		Name:           "Selectivity",
+		DisplayName:    "Selectivity",
+		Description:    "即Jensen's alpha",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+	}, JensenAlpha)
+	Register(MetricInfo{
+		Name:           "UpDownRatios",
+		DisplayName:    "Up Down Ratios",
+		Description:    "基准上涨(下跌)时组合相对基准的捕获率、次数比或胜率",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     2,
+		Options:        []ParamSpec{upDownMethod, upDownSide},
+	}, UpDownRatios)
+	Register(MetricInfo{
+		Name:           "M2Sortino",
+		DisplayName:    "M Squared for Sortino",
+		Description:    "以下行风险计算的M squared",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     2,
+		Params:         []string{ParamMAR, ParamScale},
+	}, M2Sortino)
+	Register(MetricInfo{
+		Name:           "FamaBeta",
+		DisplayName:    "Fama Beta",
+		Description:    "组合与基准年化总体标准差之比",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamScale},
+	}, FamaBeta)
+	Register(MetricInfo{
+		Name:           "OmegaExcessReturn",
+		DisplayName:    "Omega Excess Return",
+		Description:    "经组合与基准下行风险调整的年化收益率",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     2,
+		Params:         []string{ParamMAR, ParamScale},
+	}, OmegaExcessReturn)
+	Register(MetricInfo{
+		Name:           "MSquaredExcess",
+		DisplayName:    "M Squared Excess",
+		Description:    "M squared超过基准年化收益率的部分",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     3,
+		Params:         []string{ParamRf, ParamScale},
+		Options:        []ParamSpec{mSquaredExcessMethod},
+	}, MSquaredExcess)
 }
 
 func ActivePremium(c MetricCalculator) (float64, error) {
@@ -49,7 +225,7 @@ func TrackingError(c MetricCalculator) (float64, error) {
 			squareSum += diff * diff
 		}
 		variance := (squareSum - sum*sum/length) / (length - 1.0)
-		return math.Sqrt(variance * Scale), nil
+		return math.Sqrt(variance * c.config.Scale), nil
 	})
 }
 
@@ -83,42 +259,25 @@ func MSquared(c MetricCalculator) (float64, error) {
 			return math.NaN(), err
 		}
 		var n = float64(len(c.PortfolioRatio()))
-		sigp := math.Sqrt(pVar*(n-1)/n) * math.Sqrt(Scale)
-		sigm := math.Sqrt(bVar*(n-1)/n) * math.Sqrt(Scale)
-		return (pa-Rf)*sigm/sigp + Rf, nil
+		sigp := math.Sqrt(pVar*(n-1)/n) * math.Sqrt(c.config.Scale)
+		sigm := math.Sqrt(bVar*(n-1)/n) * math.Sqrt(c.config.Scale)
+		rf := c.annualRf()
+		return (pa-rf)*sigm/sigp + rf, nil
 	})
 }
 
+// AlphaBeta 组合收益率对基准收益率回归的截距与斜率, 与performance包一致使用原始收益率(由价格创建时含第一个0)
+// 超额收益率的回归以及标准误等见RegressCAPM
 func AlphaBeta(c MetricCalculator) (alpha, beta float64, err error) {
-	const (
-		AlphaKey = "Alpha"
-		BetaKey  = "Beta"
-	)
-	var exist bool
-	alpha, exist = c.scalarCache[AlphaKey]
-	if !exist {
-		rb := c.BenchRatio()
-		rp := c.PortfolioRatio()
-		xRBench := make([]float64, len(rb))
-		xRPortfolio := make([]float64, len(rp))
-		periodRf := Rf / c.Period()
-		for i, p := range rb {
-			xRBench[i] = p - periodRf
-		}
-		for i, p := range rp {
-			xRPortfolio[i] = p - periodRf
-		}
-		beta, alpha, _, _, _, _ = stats.LinearRegression(rb, rp)
-		c.scalarCache[AlphaKey] = alpha
-		c.scalarCache[BetaKey] = beta
-		return
-	}
-	beta, exist = c.scalarCache[BetaKey]
-	if !exist {
-		err = errors.New(BetaKey + " absent, internal error!")
-		return
+	value, err := c.GetOrSet("AlphaBeta", func() (interface{}, error) {
+		beta, alpha, _, _, _, _ := stats.LinearRegression(c.BenchRatio(), c.PortfolioRatio())
+		return [2]float64{alpha, beta}, nil
+	})
+	ab, ok := value.([2]float64)
+	if !ok {
+		return math.NaN(), math.NaN(), err
 	}
-	return
+	return ab[0], ab[1], err
 }
 
 func Beta(c MetricCalculator) (float64, error) {
@@ -140,17 +299,17 @@ func JensenAlpha(c MetricCalculator) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		return Rpa - Rf - beta*(Rpb-Rf), nil
+		rf := c.annualRf()
+		return Rpa - rf - beta*(Rpb-rf), nil
 	})
 }
 
 func TreynorRatio(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("TreynorRatio", func() (float64, error) {
-		periodRf := Rf / c.Period()
-		rp := c.PortfolioRatio()
+		rp, periodRf := c.rfExcess("Portfolio", c.PortfolioRatio())
 		localRP := make([]float64, len(rp))
 		copy(localRP, rp)
-		tr := Vector(localRP).AddScalarV(-periodRf).Annualize(Scale, true)
+		tr := c.annualize(Vector(localRP).AddScalarV(-periodRf))
 		_, beta, err := AlphaBeta(c)
 		if err != nil {
 			return math.NaN(), err
@@ -198,15 +357,14 @@ func AppraisalRatio(c MetricCalculator) (float64, error) {
 
 func SystematicRisk(c MetricCalculator) (float64, error) {
 	return c.GetOrSetScalar("SystematicRisk", func() (float64, error) {
-		periodRf := Rf / c.Period()
-		rp := c.BenchRatio()
+		rp, periodRf := c.rfExcess("Bench", c.BenchRatio())
 		localRP := make([]float64, len(rp))
 		copy(localRP, rp)
 		varData, err := Variance(Vector(localRP).AddScalarV(-periodRf))
 		if err != nil {
 			return math.NaN(), err
 		}
-		stdDev := math.Sqrt(varData * Scale)
+		stdDev := math.Sqrt(varData * c.config.Scale)
 		_, beta, err := AlphaBeta(c)
 		if err != nil {
 			return math.NaN(), err
@@ -224,17 +382,23 @@ func SpecificRisk(c MetricCalculator) (float64, error) {
 		Rb := c.BenchRatio()
 		Ra := c.PortfolioRatio()
 
+		// Rb为缓存的共享数据，不能用ImplVectorV原地修改
+		length := len(Ra)
+		if len(Rb) < length {
+			length = len(Rb)
+		}
 		vector := make([]float64, len(Ra))
 		copy(vector, Ra)
-		epsilon := Vector(vector).ImplVectorV(Rb, func(a, b float64) (float64, float64) {
-			return a - beta*b - alpha, b
-		})
+		for i := 0; i < length; i++ {
+			vector[i] = Ra[i] - beta*Rb[i] - alpha
+		}
+		epsilon := Vector(vector)
 		epAverage := epsilon.Average()
 		sum := Vector(vector).ImplV(func(a float64) float64 {
 			diff := (a - epAverage)
 			return diff * diff
 		}).AccumulateSum()
-		specificRisk := math.Sqrt(sum / float64(len(Rb)) * float64(Scale))
+		specificRisk := math.Sqrt(sum / float64(len(Rb)) * float64(c.config.Scale))
 		return specificRisk, nil
 	})
 }
@@ -252,3 +416,154 @@ func TotalRisk(c MetricCalculator) (float64, error) {
 		return math.Sqrt(math.Pow(sysR, 2) + math.Pow(speR, 2)), nil
 	})
 }
+
+func BenchDownsideDeviation(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("BenchDownsideDeviation", func() (float64, error) {
+		return DownsideDeviation(c.marExcess("Bench", c.BenchRatio()))
+	})
+}
+
+var upDownMethod = ParamSpec{
+	Name:        "method",
+	Description: "Capture为收益率之比, Number为上涨(下跌)次数之比, Percent为跑赢基准的次数占比",
+	Default:     "Capture",
+	Choices:     []string{"Capture", "Number", "Percent"},
+}
+
+var upDownSide = ParamSpec{
+	Name:        "side",
+	Description: "Up统计基准上涨的区间, Down统计基准下跌的区间",
+	Default:     "Up",
+	Choices:     []string{"Up", "Down"},
+}
+
+// UpDownRatios 基准上涨(下跌)时组合的表现
+// Capture: Up越大越好，Down越小越好; Number: Up越大越好，Down越小越好; Percent: Up、Down均为越大越好
+func UpDownRatios(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("UpDownRatios", func() (float64, error) {
+		ra := c.PortfolioRatio()
+		rb := c.BenchRatio()
+		if len(ra) != len(rb) {
+			return math.NaN(), errors.New("In UpDownRatios, len(portfolio) != len(bench)")
+		}
+		method := c.StringParam(upDownMethod)
+		up := c.StringParam(upDownSide) == "Up"
+		var numerator, denominator float64
+		for i := range ra {
+			inSide := rb[i] > 0
+			if !up {
+				inSide = rb[i] <= 0
+				if method != "Capture" {
+					inSide = rb[i] < 0
+				}
+			}
+			if !inSide {
+				continue
+			}
+			switch method {
+			case "Capture":
+				numerator += ra[i]
+				denominator += rb[i]
+			case "Number":
+				if (up && ra[i] > 0) || (!up && ra[i] < 0) {
+					numerator++
+				}
+				denominator++
+			case "Percent":
+				if ra[i] > rb[i] {
+					numerator++
+				}
+				denominator++
+			default:
+				return math.NaN(), errors.New("In UpDownRatios, method is default !!!")
+			}
+		}
+		return numerator / denominator, nil
+	})
+}
+
+func M2Sortino(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("M2Sortino", func() (float64, error) {
+		rp, err := PortfolioAnnualize(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		pdd, err := PortfolioDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		bdd, err := BenchDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		sr, err := PortfolioSortinoRatio(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		sigmaD := pdd * math.Sqrt(c.config.Scale)
+		sigmaDM := bdd * math.Sqrt(c.config.Scale)
+		return rp + sr*(sigmaDM-sigmaD), nil
+	})
+}
+
+func FamaBeta(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("FamaBeta", func() (float64, error) {
+		pVar, err := PortfolioVariance(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		bVar, err := BenchVariance(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		n1 := float64(len(c.PortfolioRatio()))
+		n2 := float64(len(c.BenchRatio()))
+		sigp := math.Sqrt(pVar*(n1-1)/n1) * math.Sqrt(c.config.Scale)
+		sigm := math.Sqrt(bVar*(n2-1)/n2) * math.Sqrt(c.config.Scale)
+		return sigp / sigm, nil
+	})
+}
+
+func OmegaExcessReturn(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("OmegaExcessReturn", func() (float64, error) {
+		rp, err := PortfolioAnnualize(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		pdd, err := PortfolioDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		bdd, err := BenchDownsideDeviation(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		sigmaD := pdd * math.Sqrt(c.config.Scale)
+		sigmaDM := bdd * math.Sqrt(c.config.Scale)
+		return rp - 3.0*sigmaD*sigmaDM, nil
+	})
+}
+
+var mSquaredExcessMethod = ParamSpec{
+	Name:        "method",
+	Description: "arithmetic为算术超额, geometric为几何超额",
+	Default:     "arithmetic",
+	Choices:     []string{"arithmetic", "geometric"},
+}
+
+func MSquaredExcess(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("MSquaredExcess", func() (float64, error) {
+		msq, err := MSquared(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		ba, err := BenchAnnualize(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		if c.StringParam(mSquaredExcessMethod) == "geometric" {
+			return (1.0+msq)/(1.0+ba) - 1.0, nil
+		}
+		return msq - ba, nil
+	})
+}
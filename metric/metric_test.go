@@ -3,49 +3,1321 @@ package metric_test
 import (
 	"github.com/bxy09/gfstat/metric"
 	"github.com/bxy09/gfstat/performance"
+	"github.com/bxy09/gfstat/performance/utils"
 	"math"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
+// randomWalk 以固定种子生成长度为length的组合与基准净值及逐日的日期, 每期涨跌幅在±volatility/2内均匀分布
+func randomWalk(length int, seed int64, volatility float64) (assets, bench []float64, dates []time.Time) {
+	rng := rand.New(rand.NewSource(seed))
+	dates = make([]time.Time, length)
+	assets = make([]float64, length)
+	bench = make([]float64, length)
+	now := time.Date(2015, 1, 5, 15, 0, 0, 0, time.UTC)
+	v1, v2 := 10000.0, 100.0
+	for i := range dates {
+		dates[i] = now
+		now = now.AddDate(0, 0, 1)
+		assets[i] = v1
+		bench[i] = v2
+		v1 += volatility * v1 * (rng.Float64() - 0.5)
+		v2 += volatility * v2 * (rng.Float64() - 0.5)
+	}
+	return assets, bench, dates
+}
+
+// closeEnough 以相对误差比较, NaN与NaN相等, 同号的无穷大相等
+func closeEnough(expect, value float64) bool {
+	if math.IsNaN(expect) || math.IsNaN(value) {
+		return math.IsNaN(expect) && math.IsNaN(value)
+	}
+	if expect == value {
+		return true
+	}
+	return math.Abs(expect-value) <= 0.000001*math.Max(1, math.Abs(expect))
+}
+
 func TestIdentity(t *testing.T) {
 	for _, length := range []int{0, 1, 2, 3, 4, 5, 10, 100, 500, 1000, 2000} {
 		t.Log("length=", length)
-		dates := make([]time.Time, length)
-		now := time.Now().Add(-time.Duration(length) * 24 * time.Hour)
-		assets := make([]float64, length)
-		bench := make([]float64, length)
-		var v1, v2 float64
-		v1 = 10000.0
-		v2 = 100.0
-		for i := range dates {
-			dates[i] = now
-			now = now.Add(time.Hour * 24)
-			assets[i] = v1
-			bench[i] = v2
-			v1 += 0.2 * v1 * (rand.Float64() - 0.5)
-			v2 += 0.2 * v2 * (rand.Float64() - 0.5)
-		}
-
+		assets, bench, dates := randomWalk(length, int64(length), 0.2)
 		caculator := metric.NewMetricCalculator(assets, bench, dates)
 		for key, _ := range metric.MetricMap {
+			legacy, exist := performance.LegacyPerformanceMap[key]
+			if !exist {
+				continue
+			}
 			var d1, d2 time.Duration
 			var now = time.Now()
-			pr, err := performance.PerformanceMap[key].Process(assets, bench, dates)
+			pr, err := legacy.Process(assets, bench, dates)
 			if err != nil && length > 4 {
-				t.Fatal("key", key, err)
+				t.Fatal("key", key, length, err)
 			}
 			d1 = time.Now().Sub(now)
 			mr, err := caculator.Process(key)
 			if err != nil && length > 4 {
-				t.Fatal("key", key, err)
+				t.Fatal("key", key, length, err)
 			}
-			if math.Abs(pr-mr) > 0.000001 && length > 4 {
-				t.Fatal("not identity", key, pr, mr)
+			if length > 4 && !closeEnough(pr, mr) {
+				t.Fatal("not identity", key, length, pr, mr)
 			}
 			d2 = time.Now().Sub(now) - d1
 			t.Logf("Check OK, key:%s, performance:%f(%s), metric:%f(%s) ", key, pr, d1, mr, d2)
 		}
 	}
 }
+
+func TestConfig(t *testing.T) {
+	length := 500
+	assets, _, dates := randomWalk(length, 2, 0.2)
+	moneyConfig := metric.DefaultConfig()
+	moneyConfig.Rf = 0.015
+	equity := metric.NewMetricCalculator(assets, nil, dates)
+	money := equity.WithConfig(moneyConfig)
+	es, err := equity.Process("SharpeRatio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := money.Process("SharpeRatio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if es == ms {
+		t.Fatal("results of different config mixed", es, ms)
+	}
+	fresh, err := metric.NewMetricCalculator(assets, nil, dates, moneyConfig).Process("SharpeRatio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(fresh-ms) > 0.000001 {
+		t.Fatal("shared cache gives different result", fresh, ms)
+	}
+}
+
+func TestConcurrentProcess(t *testing.T) {
+	length := 500
+	assets, bench, dates := randomWalk(length, 3, 0.2)
+	serial := metric.NewMetricCalculator(assets, bench, dates)
+	caculator := metric.NewMetricCalculator(assets, bench, dates)
+	var count int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		for key := range metric.MetricMap {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				caculator.GetOrSetScalar("Counter", func() (float64, error) {
+					atomic.AddInt32(&count, 1)
+					return 0, nil
+				})
+				value, err := caculator.Process(key)
+				expected, expectedErr := serial.Process(key)
+				if (err == nil) != (expectedErr == nil) || math.Abs(value-expected) > 0.000001 {
+					t.Error("concurrent result differs", key, value, expected, err)
+				}
+			}(key)
+		}
+	}
+	wg.Wait()
+	if count != 1 {
+		t.Fatal("cached value computed more than once", count)
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	caculator := metric.NewMetricCalculator([]float64{1, 1.1, 1.2, 1.1}, nil, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := caculator.GetOrSetScalar("Panic", func() (float64, error) {
+				time.Sleep(10 * time.Millisecond)
+				var values []float64
+				return values[1], nil
+			})
+			if err == nil || !math.IsNaN(value) {
+				t.Error("panic should be reported as an error", value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	// panic的结果不被缓存, 之后的调用重新计算
+	value, err := caculator.GetOrSetScalar("Panic", func() (float64, error) {
+		return 1, nil
+	})
+	if err != nil || value != 1 {
+		t.Fatal("entry should be retried after a panic", value, err)
+	}
+	if vector, err := caculator.GetOrSetVector("PanicVector", func() (metric.Vector, error) {
+		panic("vector")
+	}); err == nil || vector != nil {
+		t.Fatal("panic should be reported as an error", vector, err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	length := 100
+	assets, bench, dates := randomWalk(length, 4, 0.2)
+	caculator := metric.NewMetricCalculator(assets, bench, dates)
+	results, err := caculator.EvaluateAll(metric.CollectAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(metric.MetricMap) {
+		t.Fatal("missing results", len(results), len(metric.MetricMap))
+	}
+	for _, r := range results {
+		expected, _ := caculator.Process(r.Name)
+		if math.Abs(expected-r.Value) > 0.000001 {
+			t.Fatal("not identity", r.Name, expected, r.Value)
+		}
+	}
+
+	results, err = caculator.Evaluate(metric.CollectAll, "SharpeRatio", "NoSuchMetric", "Beta")
+	if err == nil || len(results) != 3 || len(results.Errors()) != 1 {
+		t.Fatal("collect all should evaluate every metric", results, err)
+	}
+	results, err = caculator.Evaluate(metric.FailFast, "SharpeRatio", "NoSuchMetric", "Beta")
+	if err == nil || len(results) != 2 {
+		t.Fatal("fail fast should stop at the first error", results, err)
+	}
+	if _, exist := results.Values()["SharpeRatio"]; !exist {
+		t.Fatal("missing value of SharpeRatio")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for key := range metric.MetricMap {
+		info, exist := metric.Info(key)
+		if !exist {
+			t.Fatal("metric without info", key)
+		}
+		if info.Name != key || info.DisplayName == "" || info.Category == "" || info.Unit == "" {
+			t.Fatal("incomplete info", info)
+		}
+	}
+	for _, info := range metric.ListMetrics(metric.ByCategory(metric.CategoryBenchmark)) {
+		if !info.RequiresBench {
+			t.Fatal("benchmark-relative metric without bench requirement", info.Name)
+		}
+	}
+	caculator := metric.NewDailyMetricCalculatorNoBench([]float64{1, 1.1, 1.05, 1.2, 1.3})
+	if _, err := caculator.Process("TrackingError"); err == nil {
+		t.Fatal("TrackingError should require a benchmark")
+	}
+	for _, info := range metric.ListMetrics(metric.NoBench) {
+		if _, err := caculator.Process(info.Name); err != nil && info.MinSamples <= 5 {
+			t.Fatal(info.Name, err)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	length := 300
+	assets, _, dates := randomWalk(length, 5, 0.2)
+	caculator := metric.NewMetricCalculator(assets, nil, dates)
+	k1, err := caculator.Process("Kappa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k3, err := caculator.Process("Kappa", metric.Params{"l": 3, "MAR": 0.02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := metric.Kappa(caculator.PortfolioRatio(), 0.02/caculator.Period(), 3)
+	if math.Abs(k3-expected) > 0.000001 || k1 == k3 {
+		t.Fatal("bad parameterized Kappa", k1, k3, expected)
+	}
+	if again, _ := caculator.Process("Kappa"); again != k1 {
+		t.Fatal("parameterized result leaked into default cache", again, k1)
+	}
+
+	config := metric.DefaultConfig()
+	config.MAR = 0.02
+	sterling, err := metric.NewMetricCalculator(assets, nil, dates, config).Process("SterlingRatio", metric.Params{"excess": 0.05})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, _ := caculator.Process("Annualized")
+	md, _ := caculator.Process("MaxDrawdown")
+	if math.Abs(sterling-ar/math.Abs(md+0.05)) > 0.000001 {
+		t.Fatal("bad parameterized SterlingRatio", sterling)
+	}
+	if _, err := caculator.Process("UpsideRisk", metric.Params{"stat": "variance"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, params := range []metric.Params{{"order": 3}, {"l": "three"}, {"stat": "risk"}, {"Rf": 0.01}, {"l": 0}, {"l": -1}, {"l": math.NaN()}} {
+		if _, err := caculator.Process("Kappa", params); err == nil {
+			t.Fatal("invalid params accepted", params)
+		}
+	}
+	if _, err := caculator.Process("SterlingRatio", metric.Params{"excess": -0.5}); err == nil {
+		t.Fatal("negative excess accepted")
+	}
+	for _, name := range []string{"Rf", "MAR", "Scale"} {
+		key := "SharpeRatio"
+		if name == "MAR" {
+			key = "SortinoRatio"
+		}
+		if _, err := caculator.Process(key, metric.Params{name: 0.5}); err != nil {
+			t.Fatal(key, name, err)
+		}
+		for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			if _, err := caculator.Process(key, metric.Params{name: value}); err == nil {
+				t.Fatal("non-finite config param accepted", key, name, value)
+			}
+		}
+	}
+	for _, info := range metric.MetricInfoMap {
+		for _, spec := range info.Options {
+			if len(spec.Choices) == 0 && !spec.Unbounded && spec.Max <= spec.Min {
+				t.Fatal("numeric parameter without a range", info.Name, spec.Name)
+			}
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Register should reject a numeric parameter without a range")
+		}
+	}()
+	metric.Register(metric.MetricInfo{Name: "NoRange", Options: []metric.ParamSpec{{Name: "x", Default: 1.0}}}, metric.PortfolioKappa)
+}
+
+func TestPortedIdentity(t *testing.T) {
+	ported := []string{"M2Sortino", "FamaBeta", "OmegaExcessReturn", "UpDownRatios", "MSquaredExcess",
+		"KellyRatio_Half", "VolatilitySkewness_Variance", "VolatilitySkewness_Risk", "MeanAbsoluteDeviation",
+		"SkewnessKurtosisRatio", "DownsideFrequency2", "Selectivity", "SharpeRatio_Annualized", "Beta"}
+	for _, length := range []int{100, 500, 1000} {
+		assets, bench, dates := randomWalk(length, 6, 0.2)
+		caculator := metric.NewMetricCalculator(assets, bench, dates)
+		for _, key := range ported {
+			pr, err := performance.LegacyPerformanceMap[key].Process(assets, bench, dates)
+			if err != nil {
+				t.Fatal("key", key, err)
+			}
+			mr, err := caculator.Process(key)
+			if err != nil {
+				t.Fatal("key", key, err)
+			}
+			if math.Abs(pr-mr) > 0.000001 {
+				t.Fatal("not identity", key, pr, mr)
+			}
+		}
+	}
+}
+
+func TestVaR(t *testing.T) {
+	array := make([]float64, 100)
+	for i := range array {
+		array[i] = float64(i + 1)
+	}
+	if v, _ := metric.VaR(array, 0.95, "historical"); math.Abs(v+5.95) > 0.000001 {
+		t.Fatal("bad historical VaR", v)
+	}
+	if v, _ := metric.VaR([]float64{-1, 0, 1}, 0.95, "gaussian"); math.Abs(v-1.6448536269514722) > 0.000001 {
+		t.Fatal("bad gaussian VaR", v)
+	}
+	if v, _ := metric.VaR([]float64{-1, 0, 1}, 0.99, "gaussian"); math.Abs(v-2.3263478740408408) > 0.000001 {
+		t.Fatal("bad gaussian VaR", v)
+	}
+
+	length := 1000
+	assets := make([]float64, length)
+	bench := make([]float64, length)
+	v1, v2 := 10000.0, 100.0
+	random := rand.New(rand.NewSource(3))
+	for i := range assets {
+		assets[i] = v1
+		bench[i] = v2
+		common := random.NormFloat64() * 0.01
+		v1 *= 1 + common + random.NormFloat64()*0.005
+		v2 *= 1 + common
+	}
+	caculator := metric.NewMetricCalculator(assets, bench, nil)
+	gaussian, err := caculator.Process("VaR", metric.Params{"method": "gaussian"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified, err := caculator.Process("VaR", metric.Params{"method": "modified"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	historical, err := caculator.Process("VaR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(gaussian-modified) > 0.003 || math.Abs(gaussian-historical) > 0.003 {
+		t.Fatal("VaR methods disagree on normal returns", gaussian, modified, historical)
+	}
+	if _, err := caculator.Process("VaR", metric.Params{"p": 1.5}); err == nil {
+		t.Fatal("confidence out of range accepted")
+	}
+	// 由价格创建时第一个补上的0不参与, 与同一收益率创建的计算器一致
+	returns := metric.NewMetricCalculatorFromReturns(caculator.PortfolioRatio()[1:], caculator.BenchRatio()[1:], nil)
+	for _, method := range []string{"historical", "gaussian", "modified"} {
+		expect, _ := caculator.Process("VaR", metric.Params{"method": method})
+		if value, err := returns.Process("VaR", metric.Params{"method": method}); err != nil || !closeEnough(expect, value) {
+			t.Fatal("VaR differs between constructors", method, expect, value, err)
+		}
+	}
+	for _, part := range []string{"bench", "specific"} {
+		expect, _ := caculator.Process("ComponentVaR", metric.Params{"part": part})
+		if value, err := returns.Process("ComponentVaR", metric.Params{"part": part}); err != nil || !closeEnough(expect, value) {
+			t.Fatal("ComponentVaR differs between constructors", part, expect, value, err)
+		}
+	}
+	benchPart, _ := caculator.Process("ComponentVaR")
+	specificPart, _ := caculator.Process("ComponentVaR", metric.Params{"part": "specific"})
+	mean := metric.Vector(caculator.PortfolioRatio()[1:]).Average()
+	if math.Abs(benchPart+specificPart-(gaussian+mean)) > 0.000001 {
+		t.Fatal("components do not sum to VaR", benchPart, specificPart, gaussian)
+	}
+}
+
+func TestES(t *testing.T) {
+	array := make([]float64, 100)
+	for i := range array {
+		array[i] = float64(i + 1)
+	}
+	// 不超过5.95的样本为1..5
+	if v, _ := metric.ES(array, 0.95, "historical"); math.Abs(v+3) > 0.000001 {
+		t.Fatal("bad historical ES", v)
+	}
+	if v, _ := metric.ES([]float64{-1, 0, 1}, 0.95, "gaussian"); math.Abs(v-2.0627128075074) > 0.000001 {
+		t.Fatal("bad gaussian ES", v)
+	}
+
+	length := 2000
+	assets := make([]float64, length)
+	v := 10000.0
+	random := rand.New(rand.NewSource(4))
+	for i := range assets {
+		assets[i] = v
+		v *= 1 + random.NormFloat64()*0.01
+	}
+	caculator := metric.NewMetricCalculator(assets, nil, nil)
+	var results []float64
+	for _, method := range []string{"historical", "gaussian", "modified"} {
+		es, err := caculator.Process("ES", metric.Params{"method": method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		vaR, _ := caculator.Process("VaR", metric.Params{"method": method})
+		if es < vaR {
+			t.Fatal("ES less than VaR", method, es, vaR)
+		}
+		results = append(results, es)
+	}
+	if math.Abs(results[0]-results[1]) > 0.003 || math.Abs(results[1]-results[2]) > 0.003 {
+		t.Fatal("ES methods disagree on normal returns", results)
+	}
+	for _, key := range []string{"STARR", "RachevRatio", "TailRatio"} {
+		value, err := caculator.Process(key)
+		if err != nil || math.IsNaN(value) {
+			t.Fatal(key, value, err)
+		}
+	}
+	if tail, _ := caculator.Process("TailRatio"); math.Abs(tail-1) > 0.2 {
+		t.Fatal("TailRatio of symmetric returns far from 1", tail)
+	}
+	// 由价格创建时第一个补上的0不参与, 与同一收益率创建的计算器一致
+	returns := metric.NewMetricCalculatorFromReturns(caculator.PortfolioRatio()[1:], nil, nil)
+	for _, key := range []string{"ES", "STARR", "RachevRatio", "TailRatio"} {
+		expect, _ := caculator.Process(key)
+		if value, err := returns.Process(key); err != nil || !closeEnough(expect, value) {
+			t.Fatal("differs between constructors", key, expect, value, err)
+		}
+	}
+}
+
+func TestDrawdownEpisodes(t *testing.T) {
+	assets := []float64{100, 110, 99, 88, 105, 110, 120, 108, 114, 90, 100}
+	dates := make([]time.Time, len(assets))
+	begin := time.Date(2015, 1, 5, 15, 0, 0, 0, time.UTC)
+	for i := range dates {
+		dates[i] = begin.AddDate(0, 0, 7*i)
+	}
+	caculator := metric.NewMetricCalculator(assets, nil, dates)
+	episodes, err := metric.DrawdownEpisodes(*caculator, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(episodes) != 2 {
+		t.Fatal("expected 2 episodes", episodes)
+	}
+	worst := episodes[0]
+	if math.Abs(worst.Depth-0.25) > 0.000001 || worst.PeakIndex != 6 || worst.TroughIndex != 9 || worst.Recovered {
+		t.Fatal("bad worst episode", worst)
+	}
+	if worst.Length != 4 || worst.CalendarDays != 28 || !worst.Peak.Equal(dates[6]) {
+		t.Fatal("bad duration of worst episode", worst)
+	}
+	second := episodes[1]
+	if math.Abs(second.Depth-0.2) > 0.000001 || !second.Recovered || second.PeakIndex != 1 || second.RecoveryIndex != 5 {
+		t.Fatal("bad second episode", second)
+	}
+	if second.Length != 4 || second.ToTrough != 2 || second.ToRecovery != 2 || !second.Recovery.Equal(dates[5]) {
+		t.Fatal("bad duration of second episode", second)
+	}
+	top, _ := metric.DrawdownEpisodes(*caculator, 1)
+	if len(top) != 1 {
+		t.Fatal("topN not applied", top)
+	}
+	md, _ := caculator.Process("MaxDrawdown")
+	if math.Abs(md-worst.Depth) > 0.000001 {
+		t.Fatal("depth differs from MaxDrawdown", md, worst.Depth)
+	}
+
+	// 由收益率创建时补上的起点在第一个收益率之前, 回撤从第一个收益率开始时高点是起点
+	falling := []float64{-0.1, 0.05, 0.1, -0.02}
+	returns := metric.NewMetricCalculatorFromReturns(falling, nil, dates[1:len(falling)+1])
+	episodes, err = metric.DrawdownEpisodes(*returns, 0)
+	if err != nil || len(episodes) != 2 {
+		t.Fatal("expected 2 episodes from returns", episodes, err)
+	}
+	first := episodes[0]
+	if first.PeakIndex != 0 || !first.Peak.Equal(dates[0]) || first.TroughIndex != 1 || !first.Trough.Equal(dates[1]) ||
+		!first.Recovered || !first.Recovery.Equal(dates[3]) || first.CalendarDays != 21 {
+		t.Fatal("bad episode from returns", first)
+	}
+	prices := metric.NewMetricCalculator(metric.Vector(falling).Prices(metric.MethodDiscrete), nil, dates[:len(falling)+1])
+	expect, _ := metric.DrawdownEpisodes(*prices, 0)
+	for i := range expect {
+		if !episodes[i].Peak.Equal(expect[i].Peak) || !episodes[i].Trough.Equal(expect[i].Trough) || episodes[i].CalendarDays != expect[i].CalendarDays {
+			t.Fatal("episodes differ between constructors", episodes[i], expect[i])
+		}
+	}
+}
+
+func TestPeriodReturns(t *testing.T) {
+	var dates []time.Time
+	var assets, bench []float64
+	day := time.Date(2014, 11, 3, 15, 0, 0, 0, time.UTC)
+	v1, v2 := 100.0, 10.0
+	random := rand.New(rand.NewSource(6))
+	for day.Before(time.Date(2016, 2, 10, 0, 0, 0, 0, time.UTC)) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			dates = append(dates, day)
+			assets = append(assets, v1)
+			bench = append(bench, v2)
+			v1 *= 1 + 0.02*(random.Float64()-0.5)
+			v2 *= 1 + 0.02*(random.Float64()-0.5)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	caculator := metric.NewMetricCalculator(assets, bench, dates)
+	months, err := metric.PeriodReturns(*caculator, metric.PeriodMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 16 || months[0].Label != "2014-11" || months[15].Label != "2016-02" {
+		t.Fatal("bad months", len(months), months[0].Label)
+	}
+	years, _ := metric.PeriodReturns(*caculator, metric.PeriodYear)
+	quarters, _ := metric.PeriodReturns(*caculator, metric.PeriodQuarter)
+	if len(years) != 3 || len(quarters) != 6 || quarters[1].Label != "2015Q1" {
+		t.Fatal("bad years or quarters", years, quarters)
+	}
+	total, totalBench := 1.0, 1.0
+	for _, m := range months {
+		total *= 1 + m.Portfolio
+		totalBench *= 1 + m.Bench
+	}
+	toDate, err := metric.ToDate(*caculator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(total-1-toDate.SinceInception.Portfolio) > 0.000001 || math.Abs(totalBench-1-toDate.SinceInception.Bench) > 0.000001 {
+		t.Fatal("monthly returns do not compound to since inception", total, toDate.SinceInception)
+	}
+	if toDate.YTD.Portfolio != toDate.QTD.Portfolio || toDate.MTD.Label != "2016-02" {
+		t.Fatal("bad to date returns", toDate)
+	}
+	table, err := metric.MonthlyReturnTable(*caculator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 3 || !math.IsNaN(table[0].Portfolio[0]) || table[1].Portfolio[4] != months[6].Portfolio {
+		t.Fatal("bad monthly table", table)
+	}
+	year := 1.0
+	for _, m := range table[1].Portfolio {
+		year *= 1 + m
+	}
+	if math.Abs(year-1-table[1].PortfolioTotal) > 0.000001 {
+		t.Fatal("months do not compound to the year", year-1, table[1].PortfolioTotal)
+	}
+}
+
+func TestRolling(t *testing.T) {
+	length := 300
+	assets, bench, dates := randomWalk(length, 7, 0.04)
+	caculator := metric.NewMetricCalculator(assets, bench, dates)
+	window, step := 60, 7
+	for _, key := range []string{"SharpeRatio", "Beta", "StdDev_Annualized", "Annualized", "MeanGeometric", "MaxDrawdown", "SortinoRatio"} {
+		series, err := caculator.Rolling(key, window, step)
+		if err != nil {
+			t.Fatal(key, err)
+		}
+		if len(series) != (length-window)/step+1 {
+			t.Fatal("bad length", key, len(series))
+		}
+		for _, v := range series {
+			if !v.Date.Equal(dates[v.End]) || v.End-v.Start+1 != window {
+				t.Fatal("bad window", key, v)
+			}
+			expect, err := metric.NewMetricCalculator(assets[v.Start:v.End+1], bench[v.Start:v.End+1], dates[v.Start:v.End+1]).Process(key)
+			if err != nil || v.Err != nil {
+				t.Fatal(key, err, v.Err)
+			}
+			if math.Abs(expect-v.Value) > 0.000001*math.Max(1, math.Abs(expect)) {
+				t.Fatal("not identity", key, v.End, expect, v.Value)
+			}
+		}
+	}
+	short, err := caculator.Rolling("Kurtosis", 3, 1)
+	if err != nil || short[0].Err == nil || !math.IsNaN(short[0].Value) {
+		t.Fatal("window shorter than MinSamples should fail per window", err, short[0])
+	}
+	if _, err := caculator.Rolling("SharpeRatio", length+1, 1); err == nil {
+		t.Fatal("window longer than data should fail")
+	}
+	if _, err := caculator.Rolling("Kappa", window, 1, metric.Params{"l": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 由收益率创建时前缀和直接按收益率计算, 与逐个窗口单独创建的计算器一致
+	pr, br := caculator.PortfolioRatio()[1:], caculator.BenchRatio()[1:]
+	returns := metric.NewMetricCalculatorFromReturns(pr, br, dates[1:])
+	for _, key := range []string{"SharpeRatio", "Beta", "StdDev_Annualized", "Annualized", "MeanGeometric", "Variance"} {
+		series, err := returns.Rolling(key, window, step)
+		if err != nil {
+			t.Fatal(key, err)
+		}
+		for _, v := range series {
+			expect, err := metric.NewMetricCalculatorFromReturns(pr[v.Start:v.End+1], br[v.Start:v.End+1], dates[v.Start+1:v.End+2]).Process(key)
+			if err != nil || v.Err != nil || !closeEnough(expect, v.Value) {
+				t.Fatal("returns not identity", key, v.End, expect, v.Value, err, v.Err)
+			}
+		}
+	}
+	// 由价格创建时窗口比收益率多一个补上的0, 不参与RegressCAPM, 两种创建方式的CAPMBeta一致
+	fromPrices, _ := caculator.Rolling("CAPMBeta", window+1, step)
+	fromReturns, _ := returns.Rolling("CAPMBeta", window, step)
+	if len(fromPrices) != len(fromReturns) {
+		t.Fatal("bad length", len(fromPrices), len(fromReturns))
+	}
+	for i := range fromPrices {
+		if !closeEnough(fromPrices[i].Value, fromReturns[i].Value) {
+			t.Fatal("CAPMBeta differs between constructors", i, fromPrices[i].Value, fromReturns[i].Value)
+		}
+	}
+
+	// 设置无风险利率序列时不走前缀和, 与逐个窗口单独设置同一序列的计算器一致
+	rates := metric.RateSeries{Dates: []time.Time{dates[0], dates[length/2]}, Rates: []float64{0.01, 0.08}}
+	withRf, err := caculator.WithRiskFree(rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"SharpeRatio", "Beta", "CAPMBeta", "SortinoRatio"} {
+		series, err := withRf.Rolling(key, window, step)
+		if err != nil {
+			t.Fatal(key, err)
+		}
+		for _, v := range series {
+			sub, err := metric.NewMetricCalculator(assets[v.Start:v.End+1], bench[v.Start:v.End+1], dates[v.Start:v.End+1]).WithRiskFree(rates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expect, err := sub.Process(key)
+			if err != nil || v.Err != nil || !closeEnough(expect, v.Value) {
+				t.Fatal("risk free series not identity", key, v.End, expect, v.Value, err, v.Err)
+			}
+		}
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	length := 400
+	walkAssets, walkBench, walkDates := randomWalk(length, 11, 0.04)
+	var dates []time.Time
+	var assets, bench []float64
+	unbounded := metric.NewStreamingCalculator(0)
+	bounded := metric.NewStreamingCalculator(50)
+	keys := []string{"Variance", "StdDev_Annualized", "Skewness", "Kurtosis", "Annualized", "MeanGeometric", "MaxDrawdown", "Beta", "SharpeRatio", "SortinoRatio"}
+	for i := 0; i < length; i++ {
+		dates = append(dates, walkDates[i])
+		assets = append(assets, walkAssets[i])
+		bench = append(bench, walkBench[i])
+		unbounded.Append(walkAssets[i], walkBench[i], walkDates[i])
+		bounded.Append(walkAssets[i], walkBench[i], walkDates[i])
+		if i < 50 || i%37 != 36 {
+			continue
+		}
+		start := len(assets) - 50
+		for _, key := range keys {
+			for _, item := range []struct {
+				s *metric.StreamingCalculator
+				c *metric.MetricCalculator
+			}{
+				{unbounded, metric.NewMetricCalculator(assets, bench, dates)},
+				{bounded, metric.NewMetricCalculator(assets[start:], bench[start:], dates[start:])},
+			} {
+				expect, err := item.c.Process(key)
+				if err != nil {
+					t.Fatal(key, err)
+				}
+				value, err := item.s.Process(key)
+				if err != nil {
+					t.Fatal(key, err)
+				}
+				if math.Abs(expect-value) > 0.000001*math.Max(1, math.Abs(expect)) {
+					t.Fatal("not identity", key, i, expect, value)
+				}
+			}
+		}
+	}
+	if bounded.Len() != 50 || unbounded.Len() != length {
+		t.Fatal("bad length", bounded.Len(), unbounded.Len())
+	}
+	alpha, beta, err := unbounded.AlphaBeta()
+	expectAlpha, expectBeta, _ := metric.AlphaBeta(*metric.NewMetricCalculator(assets, bench, dates))
+	if err != nil || math.Abs(alpha-expectAlpha) > 0.000001 || math.Abs(beta-expectBeta) > 0.000001 {
+		t.Fatal("bad alpha beta", err, alpha, beta, expectAlpha, expectBeta)
+	}
+	noBench := metric.NewStreamingCalculator(0)
+	noBench.Append(1, math.NaN(), walkDates[0])
+	if _, err := noBench.Process("Beta"); err == nil {
+		t.Fatal("beta without benchmark should fail")
+	}
+}
+
+func TestRateSeries(t *testing.T) {
+	length := 300
+	assets, bench, dates := randomWalk(length, 8, 0.04)
+	caculator := metric.NewMetricCalculator(assets, bench, dates)
+	flat := metric.RateSeries{Dates: dates[:1], Rates: []float64{0.03}}
+	withRf, err := caculator.WithRiskFree(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := withRf.WithMAR(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"SharpeRatio", "SortinoRatio", "JensenAlpha2", "TreynorRatio", "KellyRatio_Full", "Kappa", "UpsidePotentialRatio", "STARR"} {
+		expect, _ := caculator.Process(key)
+		value, err := both.Process(key)
+		if err != nil || math.Abs(expect-value) > 0.000001*math.Max(1, math.Abs(expect)) {
+			t.Fatal("flat series differs from scalar", key, expect, value, err)
+		}
+	}
+
+	// 前一半利率为0, 后一半为6%
+	series := metric.RateSeries{Dates: []time.Time{dates[0], dates[length/2]}, Rates: []float64{0, 0.06}}
+	varying, err := caculator.WithRiskFree(series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := varying.PortfolioRatio()
+	prod := 1.0
+	for i, p := range pr {
+		rf := 0.0
+		if i >= length/2 {
+			rf = 0.06 / 252
+		}
+		prod *= p - rf + 1
+	}
+	sd, _ := caculator.Process("StdDev_Annualized")
+	expect := (math.Pow(prod, 252.0/float64(len(pr))) - 1) / sd
+	sharpe, _ := varying.Process("SharpeRatio")
+	if math.Abs(expect-sharpe) > 0.000001 {
+		t.Fatal("bad sharpe with risk free series", expect, sharpe)
+	}
+	scalar, _ := varying.Process("SharpeRatio", metric.Params{"Rf": 0.03})
+	original, _ := caculator.Process("SharpeRatio")
+	if scalar != original {
+		t.Fatal("explicit Rf should override the series", scalar, original)
+	}
+	if _, err := metric.NewDailyMetricCalculatorNoBench(assets).WithRiskFree(series); err == nil {
+		t.Fatal("series without dates should fail")
+	}
+
+	// ProspectRatio按当期的MAR扣除, 对数收益率下与先对MAR取均值不同
+	config := metric.DefaultConfig()
+	config.Method = metric.MethodLog
+	marSeries := metric.RateSeries{Dates: []time.Time{dates[0], dates[length/2]}, Rates: []float64{0, 0.6}}
+	withMAR, err := metric.NewMetricCalculator(assets, bench, dates, config).WithMAR(marSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0.0
+	for i, p := range withMAR.PortfolioRatio() {
+		if p > 0 {
+			sum += p
+		} else {
+			sum += 2.25 * p
+		}
+		if i >= length/2 {
+			sum -= math.Log1p(0.6 / withMAR.Period())
+		}
+	}
+	dd, _ := withMAR.Process("DownsideDeviation2")
+	prospect, err := withMAR.Process("ProspectRatio")
+	if err != nil || !closeEnough(sum/float64(length)/dd, prospect) {
+		t.Fatal("bad ProspectRatio with MAR series", sum/float64(length)/dd, prospect, err)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2015, 1, d, 15, 0, 0, 0, time.UTC) }
+	portfolio := metric.Series{
+		Dates:  []time.Time{day(1), day(2), day(3), day(5), day(6)},
+		Values: []float64{100, 101, math.NaN(), 103, 104},
+	}
+	bench := metric.Series{
+		Dates:  []time.Time{day(2), day(3), day(4), day(5), day(6)},
+		Values: []float64{10, 11, 12, 13, math.NaN()},
+	}
+	if _, _, err := metric.NewAlignedMetricCalculator(portfolio, bench, metric.AlignInner); err == nil {
+		t.Fatal("inner join with NaN should fail")
+	}
+	_, report, err := metric.NewAlignedMetricCalculator(portfolio, bench, metric.AlignDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (metric.AlignReport{Points: 2, PortfolioDropped: 3, BenchDropped: 3}) {
+		t.Fatal("bad drop report", report)
+	}
+	p, b, dates, report, err := metric.AlignSeries(portfolio, bench, metric.AlignForwardFill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (metric.AlignReport{Points: 5, PortfolioFilled: 2, BenchFilled: 1, PortfolioDropped: 1}) {
+		t.Fatal("bad forward fill report", report)
+	}
+	if !dates[0].Equal(day(2)) || p[1] != 101 || p[2] != 101 || b[4] != 13 {
+		t.Fatal("bad forward fill", p, b, dates)
+	}
+	p, b, dates, report, err = metric.AlignSeries(portfolio, metric.Series{}, metric.AlignForwardFill)
+	if err != nil || b != nil || len(p) != len(portfolio.Values) || report.PortfolioFilled != 1 {
+		t.Fatal("bad forward fill without bench", p, b, report, err)
+	}
+	p, b, dates, report, err = metric.AlignSeries(portfolio, metric.Series{}, metric.AlignDrop)
+	if err != nil || b != nil || len(p) != 4 || report != (metric.AlignReport{Points: 4, PortfolioDropped: 1}) {
+		t.Fatal("bad drop without bench", p, b, report, err)
+	}
+	if _, _, _, _, err := metric.AlignSeries(portfolio, metric.Series{}, metric.AlignInner); err == nil {
+		t.Fatal("inner join without bench should fail on NaN")
+	}
+	clean := metric.Series{Dates: []time.Time{day(1), day(2)}, Values: []float64{100, 101}}
+	if _, _, _, report, err := metric.AlignSeries(clean, metric.Series{}, metric.AlignInner); err != nil || report != (metric.AlignReport{Points: 2}) {
+		t.Fatal("bad inner join without bench", report, err)
+	}
+	unsorted := metric.Series{Dates: []time.Time{day(2), day(1)}, Values: []float64{1, 2}}
+	if _, _, err := metric.NewAlignedMetricCalculator(unsorted, bench, metric.AlignDrop); err == nil {
+		t.Fatal("unsorted dates should fail")
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	china := metric.NewChinaCalendar()
+	var daily, weekly, minutes []time.Time
+	var prices []float64
+	day := time.Date(2015, 9, 1, 15, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	for i := 0; i < 60; i++ {
+		if china.IsTradingDay(day) {
+			daily = append(daily, day)
+			prices = append(prices, 100+float64(i))
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	if china.IsTradingDay(time.Date(2015, 10, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Fatal("Golden Week should be closed")
+	}
+	if f := metric.DetectFrequency(daily, china); f != metric.FrequencyDaily {
+		t.Fatal("daily with holidays detected as", f)
+	}
+	config := metric.DefaultConfig()
+	config.Calendar = china
+	config.Scale = 0
+	calculator := metric.NewMetricCalculator(prices, nil, daily, config)
+	if calculator.Period() != 242 || calculator.Config().Scale != 242 {
+		t.Fatal("scale should come from the calendar", calculator.Period(), calculator.Config().Scale)
+	}
+	for i := 0; i < 30; i++ {
+		weekly = append(weekly, day.AddDate(0, 0, 7*i))
+	}
+	if f := metric.DetectFrequency(weekly, metric.WeekdayCalendar{}); f != metric.FrequencyWeekly {
+		t.Fatal("weekly detected as", f)
+	}
+	if p := metric.NewMetricCalculator(make([]float64, len(weekly)), nil, weekly).Period(); p != 52 {
+		t.Fatal("bad weekly period", p)
+	}
+	for i := 0; i < 300; i++ {
+		minutes = append(minutes, day.Add(time.Duration(i)*time.Minute))
+	}
+	if f := metric.DetectFrequency(minutes, china); f != metric.FrequencyMinute || china.PeriodsPerYear(f) != 242*240 {
+		t.Fatal("bad minute frequency", f, china.PeriodsPerYear(f))
+	}
+
+	// 2015年春节休市为2月18日至24日, 农历节假日不在内置的休市日中, 需要调用者提供
+	cst := time.FixedZone("CST", 8*3600)
+	gap := []time.Time{time.Date(2015, 2, 17, 15, 0, 0, 0, cst), time.Date(2015, 2, 25, 15, 0, 0, 0, cst)}
+	if !china.IsTradingDay(time.Date(2015, 2, 18, 10, 0, 0, 0, cst)) {
+		t.Fatal("Spring Festival is not built in")
+	}
+	if f := metric.DetectFrequency(gap, china); f != metric.FrequencyWeekly {
+		t.Fatal("Spring Festival gap without holidays detected as", f)
+	}
+	var holidays []time.Time
+	for day := 18; day <= 24; day++ {
+		holidays = append(holidays, time.Date(2015, 2, day, 0, 0, 0, 0, cst))
+	}
+	springFestival := metric.NewChinaCalendar(holidays...)
+	if springFestival.IsTradingDay(time.Date(2015, 2, 18, 10, 0, 0, 0, cst)) {
+		t.Fatal("supplied holiday should be closed")
+	}
+	if f := metric.DetectFrequency(gap, springFestival); f != metric.FrequencyDaily {
+		t.Fatal("Spring Festival gap with holidays detected as", f)
+	}
+}
+
+func TestIntraday(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	var dates []time.Time
+	var assets, bench []float64
+	v1, v2 := 100.0, 10.0
+	random := rand.New(rand.NewSource(5))
+	for day := 1; day <= 5; day++ {
+		for _, session := range [][2]int{{570, 690}, {780, 900}} {
+			for m := session[0] + 1; m <= session[1]; m++ {
+				dates = append(dates, time.Date(2015, 6, day, 0, m, 0, 0, cst))
+				assets = append(assets, v1)
+				bench = append(bench, v2)
+				v1 *= 1 + 0.002*(random.Float64()-0.5)
+				v2 *= 1 + 0.002*(random.Float64()-0.5)
+			}
+		}
+	}
+	bench[10] = math.NaN()
+	hourly, err := metric.NewIntradayMetricCalculator(assets, bench, dates, utils.ExchangeAShare, utils.Bar1Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hourly.Period() != 252*4 || len(hourly.PortfolioRatio()) != 20 || len(hourly.BenchRatio()) != 20 {
+		t.Fatal("bad hourly calculator", hourly.Period(), len(hourly.PortfolioRatio()))
+	}
+	// 未传入config时按K线周期年化
+	sd, _ := hourly.Process("StdDev")
+	annualized, _ := hourly.Process("StdDev_Annualized")
+	if !closeEnough(math.Sqrt(hourly.Period())*sd, annualized) {
+		t.Fatal("hourly bars should be annualized by period", sd, annualized)
+	}
+	daily, err := metric.NewIntradayMetricCalculator(assets, nil, dates, utils.ExchangeAShare, utils.BarDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.Period() != 252 || len(daily.PortfolioRatio()) != 5 {
+		t.Fatal("bad daily calculator", daily.Period(), len(daily.PortfolioRatio()))
+	}
+}
+
+func TestReturnMethod(t *testing.T) {
+	length := 300
+	assets, bench, dates := randomWalk(length, 9, 0.04)
+	config := metric.DefaultConfig()
+	discrete := metric.NewMetricCalculator(assets, bench, dates, config)
+	config.Method = "simple"
+	simple := metric.NewMetricCalculator(assets, bench, dates, config)
+	config.Method = metric.MethodLog
+	log := metric.NewMetricCalculator(assets, bench, dates, config)
+	for _, key := range []string{"Annualized", "MeanGeometric", "MaxDrawdown"} {
+		expect, _ := discrete.Process(key)
+		if value, err := simple.Process(key); err != nil || value != expect {
+			t.Fatal("simple differs from discrete", key, expect, value, err)
+		}
+		if value, err := log.Process(key); err != nil || math.Abs(expect-value) > 1e-9*math.Max(1, math.Abs(expect)) {
+			t.Fatal("log differs from discrete", key, expect, value, err)
+		}
+	}
+	if r := log.PortfolioRatio(); math.Abs(r[1]-math.Log(assets[1]/assets[0])) > 1e-12 {
+		t.Fatal("log ratio", r[1])
+	}
+	config.Method = "geometric"
+	unknown := metric.NewMetricCalculator(assets, bench, dates, config)
+	if _, err := unknown.Process("SharpeRatio"); err == nil {
+		t.Fatal("unknown method accepted")
+	}
+	if _, err := unknown.Rolling("SharpeRatio", 60, 10); err == nil {
+		t.Fatal("unknown method accepted by Rolling")
+	}
+
+	// 盈亏序列
+	pnl := make([]float64, length-1)
+	for i := range pnl {
+		pnl[i] = assets[i+1] - assets[i]
+	}
+	config.Method = metric.MethodDifference
+	difference := metric.NewMetricCalculatorFromReturns(pnl, nil, dates[1:], config)
+	mean := metric.Vector(pnl).Average()
+	variance, _ := metric.Variance(pnl)
+	if value, _ := difference.Process("Annualized"); math.Abs(value-mean*252) > 1e-6 {
+		t.Fatal("difference Annualized", value, mean*252)
+	}
+	if value, _ := difference.Process("SharpeRatio"); math.Abs(value-mean*252/math.Sqrt(variance*252)) > 1e-9 {
+		t.Fatal("difference SharpeRatio", value)
+	}
+	if value, _ := difference.Process("MeanGeometric"); math.Abs(value-mean) > 1e-9 {
+		t.Fatal("difference MeanGeometric", value, mean)
+	}
+
+	// 累计盈亏从0开始, 回撤按差值计算
+	cumulative := []float64{0, 5, 3, 8, 2, 6}
+	days := dates[:len(cumulative)]
+	pnlCalculator := metric.NewMetricCalculator(cumulative, nil, days, config)
+	if value, err := pnlCalculator.Process("MaxDrawdown"); err != nil || value != 6 {
+		t.Fatal("difference MaxDrawdown", value, err)
+	}
+	if value, err := pnlCalculator.Process("PainIndex"); err != nil || math.Abs(value-10.0/6) > 1e-12 {
+		t.Fatal("difference PainIndex", value, err)
+	}
+	for _, key := range []string{"CalmarRatio", "AverageDrawdown", "BurkeRatio"} {
+		if value, err := pnlCalculator.Process(key); err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			t.Fatal("difference", key, value, err)
+		}
+	}
+	episodes, err := metric.DrawdownEpisodes(*pnlCalculator, 0)
+	if err != nil || len(episodes) != 2 || episodes[0].Depth != 6 || episodes[1].Depth != 2 {
+		t.Fatal("difference DrawdownEpisodes", episodes, err)
+	}
+}
+
+func TestFromReturns(t *testing.T) {
+	length := 300
+	assets, bench, dates := randomWalk(length, 10, 0.04)
+	prices := metric.NewMetricCalculator(assets, bench, dates)
+	pr := prices.PortfolioRatio()[1:]
+	returns := metric.NewMetricCalculatorFromReturns(pr, prices.BenchRatio()[1:], dates[1:])
+	if ratio := returns.PortfolioRatio(); len(ratio) != length-1 || ratio[0] != pr[0] {
+		t.Fatal("returns should be used without a leading zero", len(ratio))
+	}
+
+	prod := 1.0
+	for _, r := range pr {
+		prod *= 1 + r
+	}
+	variance, _ := metric.Variance(pr)
+	for key, expect := range map[string]float64{
+		"Annualized": math.Pow(prod, 252/float64(len(pr))) - 1,
+		"Variance":   variance,
+	} {
+		if value, err := returns.Process(key); err != nil || math.Abs(value-expect) > 1e-9*math.Max(1, math.Abs(expect)) {
+			t.Fatal(key, expect, value, err)
+		}
+	}
+	// 净值由收益率还原, 回撤以及区间收益与价格序列一致
+	for _, key := range []string{"MaxDrawdown", "PainIndex"} {
+		expect, _ := prices.Process(key)
+		if value, err := returns.Process(key); err != nil || math.Abs(value-expect) > 1e-9 {
+			t.Fatal(key, expect, value, err)
+		}
+	}
+	if nav := returns.PortfolioNAV(); len(nav) != length || math.Abs(nav[length-1]-assets[length-1]/assets[0]) > 1e-9 {
+		t.Fatal("PortfolioNAV", len(nav))
+	}
+	expectMonths, _ := metric.PeriodReturns(*prices, metric.PeriodMonth)
+	months, err := metric.PeriodReturns(*returns, metric.PeriodMonth)
+	if err != nil || len(months) != len(expectMonths) {
+		t.Fatal("PeriodReturns", len(months), len(expectMonths), err)
+	}
+	for i := range months {
+		if months[i].Label != expectMonths[i].Label || math.Abs(months[i].Portfolio-expectMonths[i].Portfolio) > 1e-9 ||
+			math.Abs(months[i].Bench-expectMonths[i].Bench) > 1e-9 {
+			t.Fatal("PeriodReturns", months[i], expectMonths[i])
+		}
+	}
+
+	rolling, err := returns.Rolling("Variance", 20, 1)
+	if err != nil || len(rolling) != len(pr)-19 {
+		t.Fatal("Rolling", len(rolling), err)
+	}
+	last, _ := metric.Variance(pr[len(pr)-20:])
+	if math.Abs(rolling[len(rolling)-1].Value-last) > 1e-12 || !rolling[len(rolling)-1].Date.Equal(dates[length-1]) {
+		t.Fatal("Rolling", rolling[len(rolling)-1], last)
+	}
+	if _, err := metric.NewMetricCalculatorFromReturns(pr[:1], nil, nil).Process("Variance"); err == nil {
+		t.Fatal("Variance should require more samples")
+	}
+
+	// difference: 由盈亏创建时净值从0开始, 回撤与区间收益按差值计算, 与由价格创建时一致
+	pnl := make([]float64, length-1)
+	for i := range pnl {
+		pnl[i] = assets[i+1] - assets[i]
+	}
+	config := metric.DefaultConfig()
+	config.Method = metric.MethodDifference
+	pnlPrices := metric.NewMetricCalculator(assets, nil, dates, config)
+	pnlReturns := metric.NewMetricCalculatorFromReturns(pnl, nil, dates[1:], config)
+	for _, key := range []string{"MaxDrawdown", "PainIndex", "AverageDrawdown"} {
+		expect, _ := pnlPrices.Process(key)
+		if value, err := pnlReturns.Process(key); err != nil || math.IsNaN(value) || math.Abs(value-expect) > 1e-9*math.Max(1, math.Abs(expect)) {
+			t.Fatal("difference", key, expect, value, err)
+		}
+	}
+	if value, err := pnlReturns.Process("CalmarRatio"); err != nil || math.IsNaN(value) {
+		t.Fatal("difference CalmarRatio", value, err)
+	}
+	expectMonths, _ = metric.PeriodReturns(*pnlPrices, metric.PeriodMonth)
+	months, err = metric.PeriodReturns(*pnlReturns, metric.PeriodMonth)
+	if err != nil || len(months) != len(expectMonths) {
+		t.Fatal("difference PeriodReturns", len(months), len(expectMonths), err)
+	}
+	for i := range months {
+		if math.IsNaN(months[i].Portfolio) || math.Abs(months[i].Portfolio-expectMonths[i].Portfolio) > 1e-9 {
+			t.Fatal("difference PeriodReturns", months[i], expectMonths[i])
+		}
+	}
+	if toDate, err := metric.ToDate(*pnlReturns); err != nil || math.Abs(toDate.SinceInception.Portfolio-(assets[length-1]-assets[0])) > 1e-6 {
+		t.Fatal("difference SinceInception", toDate.SinceInception, err)
+	}
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	length := 200
+	assets := make([]float64, length)
+	bench := make([]float64, length)
+	v1, v2 := 10000.0, 100.0
+	rng := rand.New(rand.NewSource(7))
+	for i := range assets {
+		assets[i] = v1
+		bench[i] = v2
+		v1 += 0.04 * v1 * (rng.Float64() - 0.49)
+		v2 += 0.04 * v2 * (rng.Float64() - 0.5)
+	}
+	caculator := metric.NewMetricCalculator(assets, bench, nil)
+	// 点估计与重抽样使用同样不含第一个0的收益率
+	returns := metric.NewMetricCalculatorFromReturns(caculator.PortfolioRatio()[1:], caculator.BenchRatio()[1:], nil)
+	for _, method := range []metric.BootstrapMethod{metric.BootstrapStationary, metric.BootstrapBlock} {
+		options := metric.BootstrapOptions{Method: method, Seed: 42}
+		interval, err := caculator.ConfidenceInterval("SharpeRatio", 0.9, 500, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect, _ := returns.Process("SharpeRatio")
+		if interval.Estimate != expect || interval.Resamples != 500 || !(interval.Lower < expect && expect < interval.Upper) {
+			t.Fatal("SharpeRatio interval", method, interval, expect)
+		}
+		again, _ := caculator.ConfidenceInterval("SharpeRatio", 0.9, 500, options)
+		if again != interval {
+			t.Fatal("same seed should give the same interval", interval, again)
+		}
+		wider, _ := caculator.ConfidenceInterval("SharpeRatio", 0.99, 500, options)
+		if wider.Lower > interval.Lower || wider.Upper < interval.Upper {
+			t.Fatal("higher level should give a wider interval", interval, wider)
+		}
+	}
+	beta, err := caculator.ConfidenceInterval("Beta", 0.95, 200, metric.BootstrapOptions{BlockSize: 5})
+	if err != nil || !(beta.Lower <= beta.Estimate && beta.Estimate <= beta.Upper) {
+		t.Fatal("Beta interval", beta, err)
+	}
+	kappa, err := caculator.ConfidenceInterval("Kappa", 0.95, 200, metric.BootstrapOptions{Params: metric.Params{"l": 3}})
+	if expect, _ := returns.Process("Kappa", metric.Params{"l": 3}); err != nil || kappa.Estimate != expect {
+		t.Fatal("Kappa interval", kappa, err)
+	}
+	if _, err := caculator.ConfidenceInterval("SharpeRatio", 1.5, 100); err == nil {
+		t.Fatal("level should be in (0, 1)")
+	}
+	if _, err := caculator.ConfidenceInterval("NoSuchMetric", 0.9, 100); err == nil {
+		t.Fatal("unknown metric should fail")
+	}
+}
+
+func TestDeflatedSharpe(t *testing.T) {
+	length := 500
+	assets := make([]float64, length)
+	v := 10000.0
+	rng := rand.New(rand.NewSource(3))
+	for i := range assets {
+		assets[i] = v
+		v += 0.02 * v * (rng.Float64() - 0.48)
+	}
+	config := metric.DefaultConfig()
+	config.Rf = 0
+	caculator := metric.NewMetricCalculator(assets, nil, nil, config)
+	// 不含由价格创建时补上的第一个0
+	pr := caculator.PortfolioRatio()[1:]
+	variance, _ := metric.Variance(pr)
+	sr := metric.Vector(pr).Average() / math.Sqrt(variance)
+
+	// 基准等于样本夏普比率时概率为0.5
+	if psr, err := caculator.Process("ProbabilisticSharpeRatio", metric.Params{"sr": sr * math.Sqrt(252)}); err != nil || math.Abs(psr-0.5) > 1e-12 {
+		t.Fatal("PSR at the estimate", psr, err)
+	}
+	psr, err := caculator.Process("ProbabilisticSharpeRatio")
+	if err != nil || psr <= 0.5 || psr >= 0.9999 {
+		t.Fatal("PSR", psr, err)
+	}
+	returns := metric.NewMetricCalculatorFromReturns(pr, nil, nil, config)
+	if value, err := returns.Process("ProbabilisticSharpeRatio"); err != nil || math.Abs(value-psr) > 1e-12 {
+		t.Fatal("PSR from returns should equal PSR from prices", value, psr, err)
+	}
+	// 以PSR为置信水平时, 最少期数正好是样本的期数
+	minTRL, err := caculator.Process("MinTRL", metric.Params{"p": psr})
+	if err != nil || math.Abs(minTRL-float64(len(pr))) > 1e-6 {
+		t.Fatal("MinTRL", minTRL, len(pr), err)
+	}
+	if value, _ := caculator.Process("MinTRL", metric.Params{"sr": sr*math.Sqrt(252) + 0.1}); !math.IsInf(value, 1) {
+		t.Fatal("MinTRL above the estimate should be +Inf", value)
+	}
+
+	dsr, _ := caculator.Process("DeflatedSharpeRatio")
+	if dsr != psr {
+		t.Fatal("DSR with one trial should equal PSR", dsr, psr)
+	}
+	previous := dsr
+	for _, trials := range []float64{10, 100, 1000} {
+		value, err := caculator.Process("DeflatedSharpeRatio", metric.Params{"trials": trials})
+		if err != nil || value >= previous {
+			t.Fatal("DSR should decrease with the number of trials", trials, value, previous, err)
+		}
+		previous = value
+	}
+	wide, _ := caculator.Process("DeflatedSharpeRatio", metric.Params{"trials": 100, "variance": 4})
+	narrow, _ := caculator.Process("DeflatedSharpeRatio", metric.Params{"trials": 100, "variance": 0.01})
+	if wide >= narrow {
+		t.Fatal("DSR should decrease with the variance of trials", wide, narrow)
+	}
+}
+
+func TestCompareSharpe(t *testing.T) {
+	length := 1000
+	rng := rand.New(rand.NewSource(11))
+	ra := make([]float64, length)
+	rb := make([]float64, length)
+	rc := make([]float64, length)
+	for i := range ra {
+		common := rng.NormFloat64() * 0.01
+		ra[i] = common + rng.NormFloat64()*0.005 + 0.001
+		rb[i] = common + rng.NormFloat64()*0.005 + 0.001
+		rc[i] = common + rng.NormFloat64()*0.005 - 0.002
+	}
+	for _, test := range []func(a, b []float64) (metric.SharpeTest, error){
+		metric.JobsonKorkieTest,
+		func(a, b []float64) (metric.SharpeTest, error) { return metric.LedoitWolfTest(a, b, 0) },
+	} {
+		same, err := test(ra, ra)
+		if err != nil || same.Statistic != 0 || same.PValue != 1 {
+			t.Fatal("identical series", same, err)
+		}
+		similar, _ := test(ra, rb)
+		if similar.PValue < 0.01 {
+			t.Fatal("series with the same Sharpe ratio", similar)
+		}
+		different, _ := test(ra, rc)
+		if different.PValue > 0.001 || different.Statistic <= 0 || different.Difference <= 0 {
+			t.Fatal("series with different Sharpe ratios", different)
+		}
+		swapped, _ := test(rc, ra)
+		if math.Abs(swapped.Statistic+different.Statistic) > 1e-9 || math.Abs(swapped.PValue-different.PValue) > 1e-9 {
+			t.Fatal("swapping should negate the statistic", swapped, different)
+		}
+		if _, err := test(ra, rb[1:]); err == nil {
+			t.Fatal("series with different lengths")
+		}
+	}
+
+	config := metric.DefaultConfig()
+	config.Rf = 0
+	a := metric.NewMetricCalculatorFromReturns(ra, nil, nil, config)
+	prices := metric.Vector(rc).Prices(metric.MethodDiscrete)
+	c := metric.NewMetricCalculator(prices, nil, nil, config)
+	result, err := metric.CompareSharpe(*a, *c, metric.SharpeTestJobsonKorkie)
+	expect, _ := metric.JobsonKorkieTest(ra, rc)
+	if err != nil || math.Abs(result.Statistic-expect.Statistic) > 1e-6 {
+		t.Fatal("CompareSharpe", result, expect, err)
+	}
+	result, err = metric.CompareSharpe(*a, *c, metric.SharpeTestLedoitWolf)
+	expect, _ = metric.LedoitWolfTest(ra, rc, 0)
+	if err != nil || math.Abs(result.Statistic-expect.Statistic) > 1e-6 {
+		t.Fatal("CompareSharpe", result, expect, err)
+	}
+}
+
+func TestRegressCAPM(t *testing.T) {
+	length := 2000
+	rng := rand.New(rand.NewSource(5))
+	rb := make([]float64, length)
+	ra := make([]float64, length)
+	autoBench := make([]float64, length)
+	autoPortfolio := make([]float64, length)
+	var e, x float64
+	for i := range rb {
+		rb[i] = rng.NormFloat64() * 0.01
+		ra[i] = 0.0005 + 1.2*rb[i] + rng.NormFloat64()*0.005
+		// 基准与残差都有较强的正自相关
+		x = 0.8*x + rng.NormFloat64()*0.01
+		e = 0.8*e + rng.NormFloat64()*0.005
+		autoBench[i] = x
+		autoPortfolio[i] = 0.0005 + 1.2*x + e
+	}
+	config := metric.DefaultConfig()
+	config.Rf = 0
+	c := metric.NewMetricCalculatorFromReturns(ra, rb, nil, config)
+	result, err := metric.RegressCAPM(*c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alpha, beta, _ := metric.AlphaBeta(*c)
+	if math.Abs(result.Alpha-alpha) > 1e-12 || math.Abs(result.Beta-beta) > 1e-12 || result.Observations != length {
+		t.Fatal("RegressCAPM differs from AlphaBeta", result, alpha, beta)
+	}
+	// 回归的结果通过CAPM开头的指标读取, Beta仍然使用原始收益率
+	assets, bench, dates := randomWalk(300, 12, 0.04)
+	prices := metric.NewMetricCalculator(assets, bench, dates)
+	regression, err := metric.RegressCAPM(*prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expect := range map[string]float64{"CAPMAlpha": regression.AlphaAnnualized, "CAPMBeta": regression.Beta,
+		"CAPMAlphaT": regression.AlphaT, "CAPMRSquared": regression.RSquared} {
+		if value, err := prices.Process(key); err != nil || value != expect {
+			t.Fatal(key, value, expect, err)
+		}
+	}
+	if _, beta, _ = metric.AlphaBeta(*prices); beta == regression.Beta {
+		t.Fatal("AlphaBeta should keep the raw returns with the leading zero", beta)
+	}
+	if math.Abs(result.Beta-1.2) > 4*result.BetaStdErr || math.Abs(result.BetaT-result.Beta/result.BetaStdErr) > 1e-9 {
+		t.Fatal("Beta", result)
+	}
+	if result.RSquared < 0.7 || result.RSquared > 0.95 || math.Abs(result.DurbinWatson-2) > 0.2 {
+		t.Fatal("RSquared or DurbinWatson", result)
+	}
+	if math.Abs(result.ResidualVolatility-0.005*math.Sqrt(252)) > 0.005 {
+		t.Fatal("ResidualVolatility", result.ResidualVolatility)
+	}
+
+	auto := metric.NewMetricCalculatorFromReturns(autoPortfolio, autoBench, nil, config)
+	ols, _ := metric.RegressCAPM(*auto)
+	hac, err := metric.RegressCAPM(*auto, metric.CAPMOptions{NeweyWest: true, Lag: 20})
+	if err != nil || hac.Lag != 20 || hac.Beta != ols.Beta {
+		t.Fatal("NeweyWest", hac, err)
+	}
+	if ols.DurbinWatson > 1 || hac.BetaStdErr <= ols.BetaStdErr || hac.AlphaStdErr <= ols.AlphaStdErr {
+		t.Fatal("NeweyWest errors should be larger with autocorrelated residuals", ols, hac)
+	}
+	if automatic, _ := metric.RegressCAPM(*auto, metric.CAPMOptions{NeweyWest: true}); automatic.Lag != 7 {
+		t.Fatal("automatic lag", automatic.Lag)
+	}
+	if _, err := metric.RegressCAPM(*metric.NewMetricCalculatorFromReturns(ra, nil, nil)); err == nil {
+		t.Fatal("benchmark is required")
+	}
+}
@@ -0,0 +1,195 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Params 计算指标时的命名参数, 例如 Params{"l": 3, "MAR": 0.02}
+// 数值参数可以是float64或int, 枚举参数为string
+type Params map[string]interface{}
+
+// ParamSpec 指标自身参数的声明, Choices非空时为枚举参数, 否则为数值参数
+// 数值参数需要声明Max > Min并落在[Min, Max]之内, 或者声明Unbounded表示不限范围
+type ParamSpec struct {
+	Name        string
+	Description string
+	Default     interface{}
+	Choices     []string
+	Min, Max    float64
+	Unbounded   bool
+}
+
+// check 检查声明本身, 数值参数必须给出范围或者明确声明为Unbounded
+func (spec ParamSpec) check() error {
+	if len(spec.Choices) > 0 || spec.Unbounded || spec.Max > spec.Min {
+		return nil
+	}
+	return fmt.Errorf("parameter %s should declare [Min, Max] or Unbounded", spec.Name)
+}
+
+// key 参数的规范化表示, 用于区分缓存
+func (p Params) key() string {
+	if len(p) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%v", name, p[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// validate 按元数据检查参数, 返回覆盖后的配置以及规范化的指标参数
+func (info MetricInfo) validate(config Config, params Params) (Config, Params, error) {
+	options := Params{}
+	for name, value := range params {
+		if isConfigParam(info.Params, name) {
+			f, ok := toFloat(value)
+			if !ok {
+				return config, nil, fmt.Errorf("In %s, parameter %s should be a number", info.Name, name)
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				return config, nil, fmt.Errorf("In %s, parameter %s should be finite", info.Name, name)
+			}
+			switch name {
+			case ParamRf:
+				config.Rf = f
+			case ParamMAR:
+				config.MAR = f
+			case ParamScale:
+				if f <= 0 {
+					return config, nil, fmt.Errorf("In %s, parameter %s should be positive", info.Name, name)
+				}
+				config.Scale = f
+			}
+			continue
+		}
+		spec, exist := info.option(name)
+		if !exist {
+			return config, nil, fmt.Errorf("In %s, unknown parameter %s", info.Name, name)
+		}
+		if len(spec.Choices) > 0 {
+			s, ok := value.(string)
+			if !ok || !contains(spec.Choices, s) {
+				return config, nil, fmt.Errorf("In %s, parameter %s should be one of %v", info.Name, name, spec.Choices)
+			}
+			options[name] = s
+			continue
+		}
+		f, ok := toFloat(value)
+		if !ok {
+			return config, nil, fmt.Errorf("In %s, parameter %s should be a number", info.Name, name)
+		}
+		if math.IsNaN(f) {
+			return config, nil, fmt.Errorf("In %s, parameter %s should not be NaN", info.Name, name)
+		}
+		if !spec.Unbounded && (f < spec.Min || f > spec.Max) {
+			return config, nil, fmt.Errorf("In %s, parameter %s should be in [%g, %g]", info.Name, name, spec.Min, spec.Max)
+		}
+		options[name] = f
+	}
+	return config, options, nil
+}
+
+func (info MetricInfo) option(name string) (ParamSpec, bool) {
+	for _, spec := range info.Options {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+func isConfigParam(params []string, name string) bool {
+	return name != ParamMethod && contains(params, name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FloatParam 读取数值参数, 未指定时返回spec中的默认值
+func (c MetricCalculator) FloatParam(spec ParamSpec) float64 {
+	if value, exist := c.params[spec.Name]; exist {
+		if f, ok := toFloat(value); ok {
+			return f
+		}
+	}
+	f, _ := toFloat(spec.Default)
+	return f
+}
+
+// StringParam 读取枚举参数, 未指定时返回spec中的默认值
+func (c MetricCalculator) StringParam(spec ParamSpec) string {
+	if value, exist := c.params[spec.Name]; exist {
+		if s, ok := value.(string); ok {
+			return s
+		}
+	}
+	s, _ := spec.Default.(string)
+	return s
+}
+
+// withParams 返回带有参数的计算器, 与原计算器共享缓存, 缓存键中包含参数
+func (c MetricCalculator) withParams(name string, params []Params) (MetricCalculator, error) {
+	if len(params) == 0 {
+		return c, nil
+	}
+	merged := Params{}
+	for _, p := range params {
+		for k, v := range p {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		return c, nil
+	}
+	info, exist := MetricInfoMap[name]
+	if !exist {
+		return c, errors.New("In " + name + ", parameters are not supported")
+	}
+	config, options, err := info.validate(c.config, merged)
+	if err != nil {
+		return c, err
+	}
+	c.config = config
+	c.params = options
+	// 显式指定的Rf与MAR覆盖利率序列
+	if _, exist := merged[ParamRf]; exist {
+		c.rf = nil
+	}
+	if _, exist := merged[ParamMAR]; exist {
+		c.mar = nil
+	}
+	return c, nil
+}
@@ -0,0 +1,169 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// CalendarPeriod 日历区间的类型
+type CalendarPeriod string
+
+const (
+	PeriodMonth   CalendarPeriod = "month"
+	PeriodQuarter CalendarPeriod = "quarter"
+	PeriodYear    CalendarPeriod = "year"
+)
+
+// PeriodReturn 一个日历区间的复合收益率, 无基准时Bench为NaN
+// 区间收益以上一个区间最后的净值为基础, 第一个区间以第一个观测为基础
+type PeriodReturn struct {
+	Label     string    // 2015, 2015Q1, 2015-01
+	Start     time.Time // 区间内第一个观测的时间
+	End       time.Time // 区间内最后一个观测的时间
+	Portfolio float64
+	Bench     float64
+}
+
+// periodLabel 按dates自身的时区划分日历区间
+func periodLabel(t time.Time, period CalendarPeriod) string {
+	switch period {
+	case PeriodMonth:
+		return fmt.Sprintf("%d-%02d", t.Year(), int(t.Month()))
+	case PeriodQuarter:
+		return fmt.Sprintf("%dQ%d", t.Year(), (int(t.Month())-1)/3+1)
+	default:
+		return fmt.Sprintf("%d", t.Year())
+	}
+}
+
+// compound 净值由base到end的收益, difference按差值计算, 其起点为0
+func (c MetricCalculator) compound(values []float64, base, end int) float64 {
+	if values == nil || (values[base] == 0 && c.config.Method != MethodDifference) {
+		return math.NaN()
+	}
+	return c.change(values[base], values[end])
+}
+
+func (c MetricCalculator) checkDates(name string) error {
+	if len(c.dates) == 0 {
+		return errors.New("In " + name + ", dates are required")
+	}
+	if len(c.dates) != c.length() {
+		return errors.New("In " + name + ", len(dates) != len(portfolio)")
+	}
+	if c.benchLength() > 0 && c.benchLength() != c.length() {
+		return errors.New("In " + name + ", len(bench) != len(portfolio)")
+	}
+	return nil
+}
+
+// PeriodReturns 按月、季度或年计算的组合与基准复合收益率
+func PeriodReturns(c MetricCalculator, period CalendarPeriod) ([]PeriodReturn, error) {
+	if period != PeriodMonth && period != PeriodQuarter && period != PeriodYear {
+		return nil, errors.New("In PeriodReturns, unknown period " + string(period))
+	}
+	value, err := c.GetOrSet("PeriodReturns|"+string(period), func() (interface{}, error) {
+		if err := c.checkDates("PeriodReturns"); err != nil {
+			return []PeriodReturn(nil), err
+		}
+		var returns []PeriodReturn
+		portfolio, bench, dates := c.PortfolioNAV(), c.BenchNAV(), c.navDates()
+		base, start := 0, 0
+		// 由收益率创建时起点是补上的, 归入第一个收益率所在的期间
+		if c.fromReturns() {
+			start = 1
+		}
+		for i := start; i < len(dates); i++ {
+			label := periodLabel(dates[i], period)
+			last := i == len(dates)-1
+			if !last && periodLabel(dates[i+1], period) == label {
+				continue
+			}
+			returns = append(returns, PeriodReturn{
+				Label:     label,
+				Start:     dates[start],
+				End:       dates[i],
+				Portfolio: c.compound(portfolio, base, i),
+				Bench:     c.compound(bench, base, i),
+			})
+			base, start = i, i+1
+		}
+		return returns, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]PeriodReturn), nil
+}
+
+// MonthlyReturnRow 月度收益表中的一行, 缺失的月份为NaN
+type MonthlyReturnRow struct {
+	Year           int
+	Portfolio      [12]float64
+	Bench          [12]float64
+	PortfolioTotal float64
+	BenchTotal     float64
+}
+
+// MonthlyReturnTable 按年排列的月度收益表, 每行附带全年收益
+func MonthlyReturnTable(c MetricCalculator) ([]MonthlyReturnRow, error) {
+	months, err := PeriodReturns(c, PeriodMonth)
+	if err != nil {
+		return nil, err
+	}
+	years, err := PeriodReturns(c, PeriodYear)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]MonthlyReturnRow, len(years))
+	for i, y := range years {
+		rows[i].Year = y.End.Year()
+		rows[i].PortfolioTotal = y.Portfolio
+		rows[i].BenchTotal = y.Bench
+		for m := 0; m < 12; m++ {
+			rows[i].Portfolio[m] = math.NaN()
+			rows[i].Bench[m] = math.NaN()
+		}
+	}
+	row := 0
+	for _, m := range months {
+		for rows[row].Year != m.End.Year() {
+			row++
+		}
+		rows[row].Portfolio[m.End.Month()-1] = m.Portfolio
+		rows[row].Bench[m.End.Month()-1] = m.Bench
+	}
+	return rows, nil
+}
+
+// ToDateReturns 截至最后一个观测的区间收益
+type ToDateReturns struct {
+	MTD, QTD, YTD, SinceInception PeriodReturn
+}
+
+// ToDate 计算MTD、QTD、YTD以及成立以来的收益
+func ToDate(c MetricCalculator) (ToDateReturns, error) {
+	var result ToDateReturns
+	for _, item := range []struct {
+		period CalendarPeriod
+		target *PeriodReturn
+	}{{PeriodMonth, &result.MTD}, {PeriodQuarter, &result.QTD}, {PeriodYear, &result.YTD}} {
+		returns, err := PeriodReturns(c, item.period)
+		if err != nil {
+			return result, err
+		}
+		*item.target = returns[len(returns)-1]
+	}
+	portfolio, bench := c.PortfolioNAV(), c.BenchNAV()
+	last := len(portfolio) - 1
+	result.SinceInception = PeriodReturn{
+		Label:     "SinceInception",
+		Start:     c.dates[0],
+		End:       c.dates[len(c.dates)-1],
+		Portfolio: c.compound(portfolio, 0, last),
+		Bench:     c.compound(bench, 0, last),
+	}
+	return result, nil
+}
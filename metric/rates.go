@@ -0,0 +1,127 @@
+package metric
+
+import (
+	"errors"
+	"time"
+)
+
+// RateSeries 带日期的年化利率序列, 例如SHIBOR或存款利率, Rates=0.03表示3%
+type RateSeries struct {
+	Dates []time.Time
+	Rates []float64
+}
+
+// align 按日期对齐到dates, 每个日期取不晚于该日期的最近一个利率, 早于第一个利率的日期取第一个利率
+func (s RateSeries) align(name string, dates []time.Time) ([]float64, error) {
+	if len(s.Rates) == 0 || len(s.Dates) != len(s.Rates) {
+		return nil, errors.New("In " + name + ", len(Dates) != len(Rates) or the series is empty")
+	}
+	if len(dates) == 0 {
+		return nil, errors.New("In " + name + ", dates are required")
+	}
+	for i := 1; i < len(s.Dates); i++ {
+		if s.Dates[i].Before(s.Dates[i-1]) {
+			return nil, errors.New("In " + name + ", dates of the series should be sorted")
+		}
+	}
+	aligned := make([]float64, len(dates))
+	j := 0
+	for i, date := range dates {
+		for j+1 < len(s.Dates) && !s.Dates[j+1].After(date) {
+			j++
+		}
+		aligned[i] = s.Rates[j]
+	}
+	return aligned, nil
+}
+
+// WithRiskFree 返回使用无风险利率序列的计算器, 各期的无风险收益为当期利率/Period(), 按Method换算
+// 需要计算器带有dates, 新计算器使用独立的缓存
+func (m *MetricCalculator) WithRiskFree(series RateSeries) (*MetricCalculator, error) {
+	rates, err := series.align("WithRiskFree", m.dates)
+	if err != nil {
+		return nil, err
+	}
+	calculator := *m
+	calculator.rf = rates
+	calculator.cache = newCalculatorCache()
+	return &calculator, nil
+}
+
+// WithMAR 返回使用MAR序列的计算器, 各期的MAR为当期利率/Period()
+// 需要计算器带有dates, 新计算器使用独立的缓存
+func (m *MetricCalculator) WithMAR(series RateSeries) (*MetricCalculator, error) {
+	rates, err := series.align("WithMAR", m.dates)
+	if err != nil {
+		return nil, err
+	}
+	calculator := *m
+	calculator.mar = rates
+	calculator.cache = newCalculatorCache()
+	return &calculator, nil
+}
+
+// seriesKey 区分是否使用利率序列的缓存
+func (c MetricCalculator) seriesKey() string {
+	key := ""
+	if c.rf != nil {
+		key += "|Rf=series"
+	}
+	if c.mar != nil {
+		key += "|MAR=series"
+	}
+	return key
+}
+
+// excess 收益率减去各期的利率
+func (c MetricCalculator) excess(name string, ratio Vector, rates []float64) Vector {
+	value, _ := c.GetOrSet(name, func() (interface{}, error) {
+		result := make(Vector, len(ratio))
+		for i, r := range ratio {
+			result[i] = r - c.periodRate(rates[i])
+		}
+		return result, nil
+	})
+	return value.(Vector)
+}
+
+// rfExcess 收益率以及每期的无风险收益, 有无风险利率序列时返回超额收益率与0
+// name用于区分组合与基准的缓存
+func (c MetricCalculator) rfExcess(name string, ratio Vector) (Vector, float64) {
+	if c.rf == nil {
+		return ratio, c.periodRate(c.config.Rf)
+	}
+	return c.excess(name+"RfExcess", ratio, c.rf), 0
+}
+
+// marExcess 收益率以及每期的MAR, 有MAR序列时返回超出MAR的收益率与0
+func (c MetricCalculator) marExcess(name string, ratio Vector) (Vector, float64) {
+	if c.mar == nil {
+		return ratio, c.periodRate(c.config.MAR)
+	}
+	return c.excess(name+"MARExcess", ratio, c.mar), 0
+}
+
+// excessRatio 超额收益率, 不含由价格创建时补上的第一个0
+func (c MetricCalculator) excessRatio(name string, ratio Vector) []float64 {
+	ratio, periodRf := c.rfExcess(name, ratio)
+	if !c.fromReturns() && len(ratio) > 0 {
+		ratio = ratio[1:]
+	}
+	excess := make([]float64, len(ratio))
+	for i, r := range ratio {
+		excess[i] = r - periodRf
+	}
+	return excess
+}
+
+// annualRf 年化无风险利率, 有利率序列时取序列的均值, difference为0
+func (c MetricCalculator) annualRf() float64 {
+	if c.config.Method == MethodDifference {
+		return 0
+	}
+	if c.rf == nil {
+		return c.config.Rf
+	}
+	return Vector(c.rf).Average()
+}
@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"errors"
+	"sort"
+)
+
+// Category 指标的分类
+type Category string
+
+const (
+	CategoryReturn       Category = "return"
+	CategoryRisk         Category = "risk"
+	CategoryRiskAdjusted Category = "risk-adjusted"
+	CategoryBenchmark    Category = "benchmark-relative"
+	CategoryDrawdown     Category = "drawdown"
+)
+
+// Unit 指标的单位
+type Unit string
+
+const (
+	UnitFraction Unit = "fraction" // 收益率或比例, 0.05表示5%
+	UnitRatio    Unit = "ratio"    // 无量纲的比值
+	UnitPeriods  Unit = "periods"  // 观测期数
+)
+
+// 指标使用的Config参数
+const (
+	ParamRf     = "Rf"
+	ParamMAR    = "MAR"
+	ParamScale  = "Scale"
+	ParamMethod = "Method"
+)
+
+// MetricInfo 指标的元数据
+type MetricInfo struct {
+	Name           string
+	DisplayName    string
+	Description    string
+	Category       Category
+	Unit           Unit
+	HigherIsBetter bool
+	RequiresBench  bool
+	MinSamples     int         // 最少需要的价格序列长度
+	Params         []string    // 使用到的Config参数, 可以通过Params覆盖
+	Options        []ParamSpec // 指标自身的参数
+}
+
+// MetricInfoMap 已注册指标的元数据, 与MetricMap一一对应
+var MetricInfoMap = map[string]MetricInfo{}
+
+// Register 注册指标及其元数据, 参数声明不完整时panic
+func Register(info MetricInfo, metric Metric) {
+	for _, spec := range info.Options {
+		if err := spec.check(); err != nil {
+			panic("In Register " + info.Name + ", " + err.Error())
+		}
+	}
+	MetricMap[info.Name] = metric
+	MetricInfoMap[info.Name] = info
+}
+
+// Info 查询指标的元数据
+func Info(name string) (MetricInfo, bool) {
+	info, exist := MetricInfoMap[name]
+	return info, exist
+}
+
+// ListMetrics 按名称排序列出满足filter的指标, filter为nil时列出全部
+func ListMetrics(filter func(MetricInfo) bool) []MetricInfo {
+	infos := make([]MetricInfo, 0, len(MetricInfoMap))
+	for _, info := range MetricInfoMap {
+		if filter == nil || filter(info) {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ByCategory 按分类过滤
+func ByCategory(category Category) func(MetricInfo) bool {
+	return func(info MetricInfo) bool { return info.Category == category }
+}
+
+// NoBench 过滤出不需要基准的指标
+func NoBench(info MetricInfo) bool {
+	return !info.RequiresBench
+}
+
+// check 检查输入数据是否满足指标的要求
+func (info MetricInfo) check(c MetricCalculator) error {
+	if info.RequiresBench && c.benchLength() == 0 {
+		return errors.New("In " + info.Name + ", benchmark is required")
+	}
+	if c.samples() < info.MinSamples {
+		return errors.New("In " + info.Name + ", not enough samples")
+	}
+	return nil
+}
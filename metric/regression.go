@@ -0,0 +1,228 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+func init() {
+	Register(MetricInfo{
+		Name:           "CAPMAlpha",
+		DisplayName:    "CAPM Alpha",
+		Description:    "超额收益率回归的年化截距",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     4,
+		Params:         []string{ParamRf, ParamScale},
+	}, CAPMAlpha)
+	Register(MetricInfo{
+		Name:           "CAPMBeta",
+		DisplayName:    "CAPM Beta",
+		Description:    "超额收益率回归的斜率",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     4,
+		Params:         []string{ParamRf, ParamScale},
+	}, CAPMBeta)
+	Register(MetricInfo{
+		Name:           "CAPMAlphaT",
+		DisplayName:    "CAPM Alpha t-stat",
+		Description:    "超额收益率回归截距的t统计量",
+		Category:       CategoryBenchmark,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     4,
+		Params:         []string{ParamRf, ParamScale},
+	}, CAPMAlphaT)
+	Register(MetricInfo{
+		Name:           "CAPMRSquared",
+		DisplayName:    "CAPM R-squared",
+		Description:    "超额收益率回归的拟合优度R²",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		RequiresBench:  true,
+		MinSamples:     4,
+		Params:         []string{ParamRf, ParamScale},
+	}, CAPMRSquared)
+}
+
+// CAPMAlpha RegressCAPM的年化截距
+func CAPMAlpha(c MetricCalculator) (float64, error) {
+	regression, err := RegressCAPM(c)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return regression.AlphaAnnualized, nil
+}
+
+// CAPMBeta RegressCAPM的斜率
+func CAPMBeta(c MetricCalculator) (float64, error) {
+	regression, err := RegressCAPM(c)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return regression.Beta, nil
+}
+
+// CAPMAlphaT RegressCAPM截距的t统计量
+func CAPMAlphaT(c MetricCalculator) (float64, error) {
+	regression, err := RegressCAPM(c)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return regression.AlphaT, nil
+}
+
+// CAPMRSquared RegressCAPM的拟合优度
+func CAPMRSquared(c MetricCalculator) (float64, error) {
+	regression, err := RegressCAPM(c)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return regression.RSquared, nil
+}
+
+// CAPMOptions RegressCAPM的可选参数
+type CAPMOptions struct {
+	// NeweyWest 为true时标准误与t统计量使用Newey-West(HAC)估计, 对残差的异方差与自相关稳健
+	NeweyWest bool
+	Lag       int // Newey-West的滞后阶数, <= 0时自动选择
+}
+
+// CAPMRegression 组合超额收益率对基准超额收益率的回归结果, alpha为每期的截距
+type CAPMRegression struct {
+	Alpha, Beta             float64
+	AlphaStdErr, BetaStdErr float64
+	AlphaT, BetaT           float64
+	AlphaAnnualized         float64 // Alpha * Scale
+	RSquared                float64
+	ResidualVolatility      float64 // 年化的残差标准差
+	DurbinWatson            float64 // 残差一阶自相关的检验统计量, 接近2时没有自相关
+	Observations            int
+	Lag                     int // 使用的Newey-West滞后阶数, 未使用时为0
+}
+
+// RegressCAPM 以最小二乘估计 Ra - Rf = alpha + beta * (Rb - Rf) + e
+// 与AlphaBeta不同, 回归使用超额收益率且不含由价格创建时补上的第一个0, Rf为0且由收益率创建时两者一致
+func RegressCAPM(c MetricCalculator, options ...CAPMOptions) (CAPMRegression, error) {
+	var opt CAPMOptions
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if c.benchLength() == 0 {
+		return CAPMRegression{}, errors.New("In RegressCAPM, benchmark is required")
+	}
+	key := "CAPMRegression"
+	if opt.NeweyWest {
+		key += fmt.Sprintf("|NeweyWest=%d", opt.Lag)
+	}
+	value, err := c.GetOrSet(key, func() (interface{}, error) {
+		y := c.excessRatio("Portfolio", c.PortfolioRatio())
+		x := c.excessRatio("Bench", c.BenchRatio())
+		return regressCAPM(x, y, c.config.Scale, opt)
+	})
+	result, _ := value.(CAPMRegression)
+	return result, err
+}
+
+func regressCAPM(x, y []float64, scale float64, opt CAPMOptions) (CAPMRegression, error) {
+	var result CAPMRegression
+	if len(x) != len(y) {
+		return result, errors.New("In RegressCAPM, len(bench) != len(portfolio)")
+	}
+	length := len(x)
+	if length < 3 {
+		return result, errors.New("In RegressCAPM, not enough samples")
+	}
+	n := float64(length)
+	xm, ym := Vector(x).Average(), Vector(y).Average()
+	var sxx, sxy, syy float64
+	for i := range x {
+		sxx += (x[i] - xm) * (x[i] - xm)
+		sxy += (x[i] - xm) * (y[i] - ym)
+		syy += (y[i] - ym) * (y[i] - ym)
+	}
+	if sxx == 0 {
+		return result, errors.New("In RegressCAPM, benchmark returns are constant")
+	}
+	beta := sxy / sxx
+	alpha := ym - beta*xm
+	residuals := make([]float64, length)
+	var sse, dw float64
+	for i := range x {
+		residuals[i] = y[i] - alpha - beta*x[i]
+		sse += residuals[i] * residuals[i]
+		if i > 0 {
+			d := residuals[i] - residuals[i-1]
+			dw += d * d
+		}
+	}
+	s2 := sse / (n - 2)
+	result = CAPMRegression{
+		Alpha:              alpha,
+		Beta:               beta,
+		AlphaAnnualized:    alpha * scale,
+		AlphaStdErr:        math.Sqrt(s2 * (1/n + xm*xm/sxx)),
+		BetaStdErr:         math.Sqrt(s2 / sxx),
+		ResidualVolatility: math.Sqrt(s2 * scale),
+		DurbinWatson:       dw / sse,
+		Observations:       length,
+	}
+	if syy > 0 {
+		result.RSquared = 1 - sse/syy
+	}
+	if opt.NeweyWest {
+		result.Lag = neweyWestLag(opt.Lag, length)
+		result.AlphaStdErr, result.BetaStdErr = neweyWestErrors(x, residuals, result.Lag)
+	}
+	result.AlphaT = alpha / result.AlphaStdErr
+	result.BetaT = beta / result.BetaStdErr
+	return result, nil
+}
+
+// neweyWestErrors 截距与斜率的HAC标准误, (X'X)^-1 S (X'X)^-1, S使用Bartlett核
+func neweyWestErrors(x, residuals []float64, lag int) (alphaStdErr, betaStdErr float64) {
+	n := float64(len(x))
+	var sx, sxx float64
+	for _, v := range x {
+		sx += v
+		sxx += v * v
+	}
+	det := n*sxx - sx*sx
+	inverse := [2][2]float64{{sxx / det, -sx / det}, {-sx / det, n / det}}
+	var s [2][2]float64
+	for j := 0; j <= lag; j++ {
+		weight := 1 - float64(j)/float64(lag+1)
+		for t := j; t < len(x); t++ {
+			u := [2]float64{residuals[t], residuals[t] * x[t]}
+			v := [2]float64{residuals[t-j], residuals[t-j] * x[t-j]}
+			for k := 0; k < 2; k++ {
+				for l := 0; l < 2; l++ {
+					value := u[k] * v[l]
+					if j > 0 {
+						value += v[k] * u[l]
+					}
+					s[k][l] += weight * value
+				}
+			}
+		}
+	}
+	var covariance [2][2]float64
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			for k := 0; k < 2; k++ {
+				for l := 0; l < 2; l++ {
+					covariance[i][j] += inverse[i][k] * s[k][l] * inverse[l][j]
+				}
+			}
+		}
+	}
+	return math.Sqrt(covariance[0][0]), math.Sqrt(covariance[1][1])
+}
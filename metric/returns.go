@@ -0,0 +1,93 @@
+package metric
+
+import (
+	"time"
+)
+
+// NewMetricCalculatorFromReturns 用收益率而非价格创建计算器, 收益率按config.Method解释
+// dates与收益率一一对应, dates[i]为第i个收益率的结束日期
+// 收益率直接参与计算, 不在开头补0; 只有回撤等需要净值的指标才由收益率还原净值
+func NewMetricCalculatorFromReturns(portfolioRet, benchRet []float64, dates []time.Time, config ...Config) *MetricCalculator {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if portfolioRet == nil {
+		portfolioRet = []float64{}
+	}
+	calculator := &MetricCalculator{
+		portfolioRet: portfolioRet,
+		benchRet:     benchRet,
+		dates:        dates,
+		config:       cfg,
+		cache:        newCalculatorCache(),
+	}
+	calculator.resolveConfig()
+	return calculator
+}
+
+// fromReturns 计算器是否由收益率创建
+func (c MetricCalculator) fromReturns() bool {
+	return c.portfolioRet != nil
+}
+
+// length 与dates对齐的观测个数, 由收益率创建时为收益率的个数
+func (c MetricCalculator) length() int {
+	if c.fromReturns() {
+		return len(c.portfolioRet)
+	}
+	return len(c.portfolio)
+}
+
+// benchLength 基准的观测个数, 没有基准时为0
+func (c MetricCalculator) benchLength() int {
+	if c.fromReturns() {
+		return len(c.benchRet)
+	}
+	return len(c.bench)
+}
+
+// samples 等价的价格个数, 用于检查MinSamples, n个收益率相当于n+1个价格
+func (c MetricCalculator) samples() int {
+	if c.fromReturns() {
+		return len(c.portfolioRet) + 1
+	}
+	return len(c.portfolio)
+}
+
+// PortfolioNAV 组合净值, 由收益率创建时以Prices还原, 比收益率多一个起点
+func (c MetricCalculator) PortfolioNAV() []float64 {
+	if !c.fromReturns() {
+		return c.portfolio
+	}
+	nav, _ := c.cache.getOrSet("PortfolioNAV|"+c.config.Method, func() (interface{}, error) {
+		return Vector(c.portfolioRet).Prices(c.config.Method), nil
+	})
+	return nav.(Vector)
+}
+
+// BenchNAV 基准净值, 没有基准时为nil
+func (c MetricCalculator) BenchNAV() []float64 {
+	if !c.fromReturns() {
+		return c.bench
+	}
+	if c.benchRet == nil {
+		return nil
+	}
+	nav, _ := c.cache.getOrSet("BenchNAV|"+c.config.Method, func() (interface{}, error) {
+		return Vector(c.benchRet).Prices(c.config.Method), nil
+	})
+	return nav.(Vector)
+}
+
+// navDates 与净值对齐的日期, 由收益率创建时起点取第一个收益率之前一个观测间隔的日期, 只有一个收益率时取前一天
+func (c MetricCalculator) navDates() []time.Time {
+	if !c.fromReturns() || len(c.dates) == 0 {
+		return c.dates
+	}
+	step := 24 * time.Hour
+	if len(c.dates) > 1 {
+		step = c.dates[1].Sub(c.dates[0])
+	}
+	return append([]time.Time{c.dates[0].Add(-step)}, c.dates...)
+}
@@ -0,0 +1,281 @@
+package metric
+
+import (
+	"errors"
+	"math"
+)
+
+func init() {
+	Register(MetricInfo{
+		Name:           "VaR",
+		DisplayName:    "Value at Risk",
+		Description:    "置信水平p下单期的最大损失，以正数表示",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     4,
+		Options:        []ParamSpec{riskMethod, riskConfidence},
+	}, PortfolioVaR)
+	Register(MetricInfo{
+		Name:           "ComponentVaR",
+		DisplayName:    "Component VaR",
+		Description:    "高斯VaR中由基准(系统)部分或特质部分贡献的份额",
+		Category:       CategoryBenchmark,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		RequiresBench:  true,
+		MinSamples:     4,
+		Options:        []ParamSpec{componentPart, riskConfidence},
+	}, ComponentVaR)
+	Register(MetricInfo{
+		Name:           "ES",
+		DisplayName:    "Expected Shortfall",
+		Description:    "超过VaR时的平均损失(CVaR)，以正数表示",
+		Category:       CategoryRisk,
+		Unit:           UnitFraction,
+		HigherIsBetter: false,
+		MinSamples:     4,
+		Options:        []ParamSpec{riskMethod, riskConfidence},
+	}, PortfolioES)
+	Register(MetricInfo{
+		Name:           "STARR",
+		DisplayName:    "STARR Ratio",
+		Description:    "平均超额收益与Expected Shortfall之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     4,
+		Params:         []string{ParamRf},
+		Options:        []ParamSpec{riskMethod, riskConfidence},
+	}, PortfolioSTARR)
+	Register(MetricInfo{
+		Name:           "RachevRatio",
+		DisplayName:    "Rachev Ratio",
+		Description:    "超额收益右尾的平均收益与左尾的平均损失之比",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Params:         []string{ParamRf},
+		Options:        []ParamSpec{riskConfidence},
+	}, PortfolioRachevRatio)
+	Register(MetricInfo{
+		Name:           "TailRatio",
+		DisplayName:    "Tail Ratio",
+		Description:    "右尾分位数与左尾分位数绝对值之比",
+		Category:       CategoryRisk,
+		Unit:           UnitRatio,
+		HigherIsBetter: true,
+		MinSamples:     2,
+		Options:        []ParamSpec{riskConfidence},
+	}, PortfolioTailRatio)
+}
+
+var riskMethod = ParamSpec{
+	Name:        "method",
+	Description: "historical为历史模拟, gaussian为正态参数法, modified为Cornish-Fisher修正",
+	Default:     "historical",
+	Choices:     []string{"historical", "gaussian", "modified"},
+}
+
+var riskConfidence = ParamSpec{
+	Name:        "p",
+	Description: "置信水平",
+	Default:     0.95,
+	Min:         0.5,
+	Max:         0.9999,
+}
+
+// cornishFisher 经偏度与超额峰度修正的正态分位数
+func cornishFisher(z, skewness, kurtosis float64) float64 {
+	return z + (z*z-1)*skewness/6 + (z*z*z-3*z)*kurtosis/24 - (2*z*z*z-5*z)*skewness*skewness/36
+}
+
+// VaR 在置信水平p下的单期风险价值, 以正数表示损失
+func VaR(array []float64, p float64, method string) (float64, error) {
+	if len(array) < 1 {
+		return math.NaN(), errors.New("In VaR, Ra.Count() < 1")
+	}
+	switch method {
+	case "historical":
+		return -Quantile(array, 1-p), nil
+	case "gaussian", "modified":
+		varData, err := Variance(array)
+		if err != nil {
+			return math.NaN(), err
+		}
+		z := normQuantile(1 - p)
+		if method == "modified" {
+			s, err := Skewness(array)
+			if err != nil {
+				return math.NaN(), err
+			}
+			k, err := Kurtosis(array)
+			if err != nil {
+				return math.NaN(), err
+			}
+			z = cornishFisher(z, s, k)
+		}
+		return -(Vector(array).Average() + z*math.Sqrt(varData)), nil
+	default:
+		return math.NaN(), errors.New("In VaR, method is default !!!")
+	}
+}
+
+// riskSample 风险度量使用的收益率样本, 由价格创建时去掉第一个补上的0, 与excessRatio一致
+func (c MetricCalculator) riskSample(ratio []float64) []float64 {
+	if !c.fromReturns() && len(ratio) > 0 {
+		return ratio[1:]
+	}
+	return ratio
+}
+
+func PortfolioVaR(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioVaR", func() (float64, error) {
+		return VaR(c.riskSample(c.PortfolioRatio()), c.FloatParam(riskConfidence), c.StringParam(riskMethod))
+	})
+}
+
+var componentPart = ParamSpec{
+	Name:        "part",
+	Description: "bench为基准(系统)部分, specific为特质部分",
+	Default:     "bench",
+	Choices:     []string{"bench", "specific"},
+}
+
+// ComponentVaR 将组合收益分解为beta*Rb与残差两部分, 计算两者对零均值高斯VaR的贡献, 两部分之和为-z*sigma
+func ComponentVaR(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("ComponentVaR", func() (float64, error) {
+		pr, br := c.riskSample(c.PortfolioRatio()), c.riskSample(c.BenchRatio())
+		if len(pr) != len(br) {
+			return math.NaN(), errors.New("In ComponentVaR, Ra.Count() != Rb.Count()")
+		}
+		pVar, err := Variance(pr)
+		if err != nil {
+			return math.NaN(), err
+		}
+		bVar, err := Variance(br)
+		if err != nil {
+			return math.NaN(), err
+		}
+		pMean, bMean := Vector(pr).Average(), Vector(br).Average()
+		covariance := 0.0
+		for i := range pr {
+			covariance += (pr[i] - pMean) * (br[i] - bMean)
+		}
+		beta := covariance / float64(len(pr)-1) / bVar
+		z := normQuantile(1 - c.FloatParam(riskConfidence))
+		benchPart := beta * beta * bVar
+		contribution := benchPart
+		if c.StringParam(componentPart) == "specific" {
+			contribution = pVar - benchPart
+		}
+		return -z * contribution / math.Sqrt(pVar), nil
+	})
+}
+
+// lowerTailMean 不超过1-p分位数的样本均值
+func lowerTailMean(array []float64, p float64) float64 {
+	q := Quantile(array, 1-p)
+	sum := 0.0
+	count := 0
+	for _, value := range array {
+		if value <= q {
+			sum += value
+			count++
+		}
+	}
+	return sum / float64(count)
+}
+
+// upperTailMean 不低于p分位数的样本均值
+func upperTailMean(array []float64, p float64) float64 {
+	q := Quantile(array, p)
+	sum := 0.0
+	count := 0
+	for _, value := range array {
+		if value >= q {
+			sum += value
+			count++
+		}
+	}
+	return sum / float64(count)
+}
+
+// ES 在置信水平p下的Expected Shortfall, 以正数表示损失
+// modified方法对Cornish-Fisher展开后的分布在1-p分位数以下求条件期望
+func ES(array []float64, p float64, method string) (float64, error) {
+	if len(array) < 1 {
+		return math.NaN(), errors.New("In ES, Ra.Count() < 1")
+	}
+	switch method {
+	case "historical":
+		return -lowerTailMean(array, p), nil
+	case "gaussian", "modified":
+		varData, err := Variance(array)
+		if err != nil {
+			return math.NaN(), err
+		}
+		alpha := 1 - p
+		z := normQuantile(alpha)
+		phi := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+		// I_k = 标准正态下 z^k 在 (-inf, z] 上的积分
+		i0 := alpha
+		i1 := -phi
+		tail := i1
+		if method == "modified" {
+			s, err := Skewness(array)
+			if err != nil {
+				return math.NaN(), err
+			}
+			k, err := Kurtosis(array)
+			if err != nil {
+				return math.NaN(), err
+			}
+			i2 := alpha - z*phi
+			i3 := -(z*z + 2) * phi
+			tail = i1 + (i2-i0)*s/6 + (i3-3*i1)*k/24 - (2*i3-5*i1)*s*s/36
+		}
+		return -(Vector(array).Average() + math.Sqrt(varData)*tail/alpha), nil
+	default:
+		return math.NaN(), errors.New("In ES, method is default !!!")
+	}
+}
+
+func PortfolioES(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioES", func() (float64, error) {
+		return ES(c.riskSample(c.PortfolioRatio()), c.FloatParam(riskConfidence), c.StringParam(riskMethod))
+	})
+}
+
+func PortfolioSTARR(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioSTARR", func() (float64, error) {
+		es, err := PortfolioES(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return Vector(c.excessRatio("Portfolio", c.PortfolioRatio())).Average() / es, nil
+	})
+}
+
+func PortfolioRachevRatio(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioRachevRatio", func() (float64, error) {
+		excess := c.excessRatio("Portfolio", c.PortfolioRatio())
+		if len(excess) < 1 {
+			return math.NaN(), errors.New("In RachevRatio, Ra.Count() < 1")
+		}
+		p := c.FloatParam(riskConfidence)
+		return upperTailMean(excess, p) / -lowerTailMean(excess, p), nil
+	})
+}
+
+func PortfolioTailRatio(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioTailRatio", func() (float64, error) {
+		pr := c.riskSample(c.PortfolioRatio())
+		if len(pr) < 1 {
+			return math.NaN(), errors.New("In TailRatio, Ra.Count() < 1")
+		}
+		p := c.FloatParam(riskConfidence)
+		return math.Abs(Quantile(pr, p)) / math.Abs(Quantile(pr, 1-p)), nil
+	})
+}
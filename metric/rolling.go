@@ -0,0 +1,268 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RollingValue 一个窗口的计算结果, Date为窗口最后一个观测的时间, dates缺省时为零值
+type RollingValue struct {
+	Date       time.Time
+	Start, End int // 窗口在原序列中的下标范围[Start, End]
+	Value      float64
+	Err        error
+}
+
+type RollingSeries []RollingValue
+
+// Values 各窗口的指标值, 计算失败的窗口为NaN
+func (s RollingSeries) Values() []float64 {
+	values := make([]float64, len(s))
+	for i, v := range s {
+		values[i] = v.Value
+	}
+	return values
+}
+
+// Dates 各窗口最后一个观测的时间
+func (s RollingSeries) Dates() []time.Time {
+	dates := make([]time.Time, len(s))
+	for i, v := range s {
+		dates[i] = v.Date
+	}
+	return dates
+}
+
+// Rolling 在长度为window的滑动窗口上计算指标, 窗口每次向前移动step个观测
+// 第一个窗口结束于第window个观测, 每个窗口的结果与用该窗口的数据单独创建计算器一致,
+// 但所有窗口共享整个序列的收益率与Period, 常用指标通过前缀和在O(1)内得到每个窗口的值
+func (c MetricCalculator) Rolling(name string, window, step int, params ...Params) (RollingSeries, error) {
+	metric, exist := MetricMap[name]
+	if !exist {
+		return nil, errors.New("No such metric")
+	}
+	if window < 1 || step < 1 {
+		return nil, errors.New("In Rolling, window and step should be positive")
+	}
+	if err := c.checkMethod(); err != nil {
+		return nil, err
+	}
+	if window > c.length() {
+		return nil, errors.New("In Rolling, window is longer than the data")
+	}
+	if c.dates != nil && len(c.dates) != c.length() {
+		return nil, errors.New("In Rolling, len(dates) != len(portfolio)")
+	}
+	if c.benchLength() > 0 && c.benchLength() != c.length() {
+		return nil, errors.New("In Rolling, len(bench) != len(portfolio)")
+	}
+	c, err := c.withParams(name, params)
+	if err != nil {
+		return nil, err
+	}
+	r := newRoller(c)
+	fast := rollingFastMap[name]
+	var series RollingSeries
+	for end := window - 1; end < c.length(); end += step {
+		start := end - window + 1
+		sub := r.window(start, end)
+		value := RollingValue{Start: start, End: end}
+		if c.dates != nil {
+			value.Date = c.dates[end]
+		}
+		if info, exist := MetricInfoMap[name]; exist {
+			value.Err = info.check(sub)
+		}
+		if value.Err == nil {
+			var ok bool
+			if fast != nil && r.pr != nil {
+				value.Value, ok = fast(r, sub, start, end)
+			}
+			if !ok {
+				value.Value, value.Err = metric(sub)
+			}
+		}
+		if value.Err != nil {
+			value.Value = math.NaN()
+		}
+		series = append(series, value)
+	}
+	return series, nil
+}
+
+// roller 滚动计算共享的数据, 前缀和按需计算
+type roller struct {
+	c        MetricCalculator
+	period   float64
+	pr, br   Vector
+	prefixes map[string][]float64
+}
+
+func newRoller(c MetricCalculator) *roller {
+	r := &roller{
+		c:        c,
+		period:   c.Period(),
+		pr:       c.PortfolioRatio(),
+		prefixes: map[string][]float64{},
+	}
+	if c.benchLength() > 0 {
+		r.br = c.BenchRatio()
+	}
+	return r
+}
+
+// window 窗口[start, end]上的计算器, 收益率从整个序列的收益率中截取
+func (r *roller) window(start, end int) MetricCalculator {
+	sub := r.c
+	if r.c.fromReturns() {
+		sub.portfolioRet = r.pr[start : end+1]
+		if r.br != nil {
+			sub.benchRet = r.br[start : end+1]
+		}
+	} else {
+		sub.portfolio = r.c.portfolio[start : end+1]
+		if r.c.bench != nil {
+			sub.bench = r.c.bench[start : end+1]
+		}
+	}
+	if r.c.dates != nil {
+		sub.dates = r.c.dates[start : end+1]
+	}
+	if r.c.rf != nil {
+		sub.rf = r.c.rf[start : end+1]
+	}
+	if r.c.mar != nil {
+		sub.mar = r.c.mar[start : end+1]
+	}
+	sub.period = r.period
+	sub.cache = newCalculatorCache()
+	if r.c.fromReturns() {
+		return sub
+	}
+	method := r.c.config.Method
+	sub.cache.getOrSet("PortfolioRatio|"+method, func() (interface{}, error) {
+		return windowRatio(r.pr, start, end), nil
+	})
+	if r.c.bench != nil {
+		sub.cache.getOrSet("BenchRatio|"+method, func() (interface{}, error) {
+			return windowRatio(r.br, start, end), nil
+		})
+	}
+	return sub
+}
+
+// windowRatio 窗口内的收益率, 与ReturnRatio一致, 第一个值为0
+func windowRatio(ratio Vector, start, end int) Vector {
+	if ratio == nil {
+		return nil
+	}
+	v := make(Vector, end-start+1)
+	copy(v[1:], ratio[start+1:end+1])
+	return v
+}
+
+// sum 窗口[start, end]内f(ratio)之和, 由价格创建时窗口的第一个收益率为0
+func (r *roller) sum(key string, f func(p, b float64) float64, start, end int) float64 {
+	prefix, exist := r.prefixes[key]
+	if !exist {
+		prefix = make([]float64, len(r.pr)+1)
+		for i, p := range r.pr {
+			b := 0.0
+			if r.br != nil {
+				b = r.br[i]
+			}
+			prefix[i+1] = prefix[i] + f(p, b)
+		}
+		r.prefixes[key] = prefix
+	}
+	if r.c.fromReturns() {
+		return prefix[end+1] - prefix[start]
+	}
+	return prefix[end+1] - prefix[start+1] + f(0, 0)
+}
+
+// rollingFast 可以由前缀和直接得到窗口值的指标, ok为false时退回到完整计算
+type rollingFast func(r *roller, c MetricCalculator, start, end int) (value float64, ok bool)
+
+var rollingFastMap = map[string]rollingFast{
+	"Variance": rollingVariance,
+	"StdDev": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		v, ok := rollingVariance(r, c, start, end)
+		return math.Sqrt(v), ok
+	},
+	"StdDev_Annualized": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		v, ok := rollingVariance(r, c, start, end)
+		return math.Sqrt(c.config.Scale) * math.Sqrt(v), ok
+	},
+	"Annualized": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		logSum, ok := rollingLogSum(r, 0, start, end)
+		n := float64(end - start + 1)
+		return math.Exp(logSum*c.config.Scale/n) - 1, ok
+	},
+	"MeanGeometric": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		logSum, ok := rollingLogSum(r, 0, start, end)
+		n := float64(end - start + 1)
+		return math.Exp(logSum/n) - 1, ok
+	},
+	"SharpeRatio": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		v, ok := rollingVariance(r, c, start, end)
+		if !ok || c.rf != nil {
+			return math.NaN(), false
+		}
+		logSum, ok := rollingLogSum(r, c.config.Rf/r.period, start, end)
+		n := float64(end - start + 1)
+		numerator := math.Exp(logSum*c.config.Scale/n) - 1
+		return numerator / (math.Sqrt(c.config.Scale) * math.Sqrt(v)), ok
+	},
+	"Beta": func(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+		if r.br == nil || c.rf != nil || end-start+1 <= 2 {
+			return math.NaN(), false
+		}
+		n := float64(end - start + 1)
+		sp := r.sum("p", func(p, b float64) float64 { return p }, start, end)
+		sb := r.sum("b", func(p, b float64) float64 { return b }, start, end)
+		sbb := r.sum("bb", func(p, b float64) float64 { return b * b }, start, end)
+		spb := r.sum("pb", func(p, b float64) float64 { return p * b }, start, end)
+		denominator := n*sbb - sb*sb
+		if denominator == 0 {
+			return math.NaN(), false
+		}
+		return (n*spb - sp*sb) / denominator, true
+	},
+}
+
+// rollingVariance 与Variance一致, 数据不足时退回到完整计算以得到相同的错误
+func rollingVariance(r *roller, c MetricCalculator, start, end int) (float64, bool) {
+	n := float64(end - start + 1)
+	if n <= 2 {
+		return math.NaN(), false
+	}
+	sum := r.sum("p", func(p, b float64) float64 { return p }, start, end)
+	squareSum := r.sum("pp", func(p, b float64) float64 { return p * p }, start, end)
+	return (squareSum - sum*sum/n) / (n - 1.0), true
+}
+
+// rollingLogSum 窗口内log(1+p-shift)之和, 存在非正的因子或者收益率不是discrete时ok为false
+func rollingLogSum(r *roller, shift float64, start, end int) (float64, bool) {
+	if r.c.config.Method != MethodDiscrete {
+		return math.NaN(), false
+	}
+	key := fmt.Sprintf("log|%g", shift)
+	bad := r.sum("bad"+key, func(p, b float64) float64 {
+		if 1+p-shift <= 0 {
+			return 1
+		}
+		return 0
+	}, start, end)
+	if bad > 0 {
+		return math.NaN(), false
+	}
+	return r.sum(key, func(p, b float64) float64 {
+		if 1+p-shift <= 0 {
+			return 0
+		}
+		return math.Log(1 + p - shift)
+	}, start, end), true
+}
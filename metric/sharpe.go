@@ -0,0 +1,154 @@
+package metric
+
+import (
+	"math"
+)
+
+func init() {
+	Register(MetricInfo{
+		Name:           "ProbabilisticSharpeRatio",
+		DisplayName:    "Probabilistic Sharpe Ratio",
+		Description:    "考虑样本长度、偏度与峰度后, 真实夏普比率超过基准夏普比率sr的概率",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     5,
+		Params:         []string{ParamRf},
+		Options:        []ParamSpec{sharpeBenchmark},
+	}, PortfolioProbabilisticSharpeRatio)
+	Register(MetricInfo{
+		Name:           "DeflatedSharpeRatio",
+		DisplayName:    "Deflated Sharpe Ratio",
+		Description:    "以trials次尝试中最大夏普比率的期望为基准的Probabilistic Sharpe Ratio",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitFraction,
+		HigherIsBetter: true,
+		MinSamples:     5,
+		Params:         []string{ParamRf},
+		Options:        []ParamSpec{sharpeTrials, sharpeTrialsVariance},
+	}, PortfolioDeflatedSharpeRatio)
+	Register(MetricInfo{
+		Name:           "MinTRL",
+		DisplayName:    "Minimum Track Record Length",
+		Description:    "在置信水平p下确认夏普比率超过基准sr所需的最少期数",
+		Category:       CategoryRiskAdjusted,
+		Unit:           UnitPeriods,
+		HigherIsBetter: false,
+		MinSamples:     5,
+		Params:         []string{ParamRf},
+		Options:        []ParamSpec{sharpeBenchmark, riskConfidence},
+	}, PortfolioMinTRL)
+}
+
+var sharpeBenchmark = ParamSpec{
+	Name:        "sr",
+	Description: "年化的基准夏普比率",
+	Default:     0.0,
+	Min:         -10,
+	Max:         10,
+}
+
+var sharpeTrials = ParamSpec{
+	Name:        "trials",
+	Description: "选出该策略之前尝试过的独立策略个数",
+	Default:     1.0,
+	Min:         1,
+	Max:         1e9,
+}
+
+var sharpeTrialsVariance = ParamSpec{
+	Name:        "variance",
+	Description: "各次尝试的年化夏普比率的方差, 0表示使用夏普比率估计量的方差",
+	Default:     0.0,
+	Min:         0,
+	Max:         1e6,
+}
+
+// eulerGamma Euler-Mascheroni常数
+const eulerGamma = 0.5772156649015329
+
+// sharpeStat 每期(非年化)的算术夏普比率, 以及估计量方差中的因子 1 - γ3*SR + (γ4-1)/4*SR^2
+type sharpeStat struct {
+	sr, factor, n float64
+}
+
+func portfolioSharpeStat(c MetricCalculator) (sharpeStat, error) {
+	value, err := c.GetOrSet("PortfolioSharpeStat", func() (interface{}, error) {
+		// 夏普比率、偏度与峰度都在同一个不含补上的0的超额收益率样本上计算
+		pr := c.excessRatio("Portfolio", c.PortfolioRatio())
+		variance, err := Variance(pr)
+		if err != nil {
+			return sharpeStat{}, err
+		}
+		skewness, err := Skewness(pr)
+		if err != nil {
+			return sharpeStat{}, err
+		}
+		// Kurtosis为超额峰度
+		kurtosis, err := Kurtosis(pr)
+		if err != nil {
+			return sharpeStat{}, err
+		}
+		sr := Vector(pr).Average() / math.Sqrt(variance)
+		return sharpeStat{
+			sr:     sr,
+			factor: 1 - skewness*sr + (kurtosis+2)/4*sr*sr,
+			n:      float64(len(pr)),
+		}, nil
+	})
+	stat, _ := value.(sharpeStat)
+	return stat, err
+}
+
+// psr 真实夏普比率超过每期基准benchmark的概率
+func (s sharpeStat) psr(benchmark float64) float64 {
+	return normCDF((s.sr - benchmark) * math.Sqrt(s.n-1) / math.Sqrt(s.factor))
+}
+
+func PortfolioProbabilisticSharpeRatio(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioProbabilisticSharpeRatio", func() (float64, error) {
+		stat, err := portfolioSharpeStat(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return stat.psr(c.FloatParam(sharpeBenchmark) / math.Sqrt(c.config.Scale)), nil
+	})
+}
+
+// PortfolioDeflatedSharpeRatio 基准为trials次尝试中最大夏普比率的期望
+// SR0 = sqrt(V) * ((1-γ)*Z(1-1/N) + γ*Z(1-1/(N*e))), trials为1时基准为0
+func PortfolioDeflatedSharpeRatio(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioDeflatedSharpeRatio", func() (float64, error) {
+		stat, err := portfolioSharpeStat(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		trials := c.FloatParam(sharpeTrials)
+		variance := c.FloatParam(sharpeTrialsVariance) / c.config.Scale
+		if variance == 0 {
+			variance = stat.factor / (stat.n - 1)
+		}
+		benchmark := 0.0
+		if trials > 1 {
+			benchmark = math.Sqrt(variance) * ((1-eulerGamma)*normQuantile(1-1/trials) +
+				eulerGamma*normQuantile(1-1/(trials*math.E)))
+		}
+		return stat.psr(benchmark), nil
+	})
+}
+
+// PortfolioMinTRL 最少期数 1 + factor * (Z(p) / (SR - SR0))^2, 夏普比率不超过基准时为+Inf
+func PortfolioMinTRL(c MetricCalculator) (float64, error) {
+	return c.GetOrSetScalar("PortfolioMinTRL", func() (float64, error) {
+		stat, err := portfolioSharpeStat(c)
+		if err != nil {
+			return math.NaN(), err
+		}
+		benchmark := c.FloatParam(sharpeBenchmark) / math.Sqrt(c.config.Scale)
+		if stat.sr <= benchmark {
+			return math.Inf(1), nil
+		}
+		z := normQuantile(c.FloatParam(riskConfidence)) / (stat.sr - benchmark)
+		return 1 + stat.factor*z*z, nil
+	})
+}
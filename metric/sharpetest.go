@@ -0,0 +1,154 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SharpeTestMethod 夏普比率之差的检验方法
+type SharpeTestMethod int
+
+const (
+	// SharpeTestJobsonKorkie 经Memmel修正的Jobson-Korkie检验, 假设收益率独立同分布且服从正态分布
+	SharpeTestJobsonKorkie SharpeTestMethod = iota
+	// SharpeTestLedoitWolf Ledoit-Wolf检验, 以Newey-West(HAC)估计协方差, 对厚尾与自相关稳健
+	SharpeTestLedoitWolf
+)
+
+// SharpeTest 两个策略夏普比率之差的检验结果, 原假设为两者的夏普比率相等
+type SharpeTest struct {
+	SharpeA, SharpeB float64 // 每期(非年化)的夏普比率
+	Difference       float64 // SharpeA - SharpeB
+	Statistic        float64 // 渐近服从标准正态分布的检验统计量
+	PValue           float64 // 双侧p值
+}
+
+func newSharpeTest(ma, mb, sa, sb, statistic float64) SharpeTest {
+	return SharpeTest{
+		SharpeA:    ma / sa,
+		SharpeB:    mb / sb,
+		Difference: ma/sa - mb/sb,
+		Statistic:  statistic,
+		PValue:     2 * (1 - normCDF(math.Abs(statistic))),
+	}
+}
+
+// zStatistic 差值除以标准差, 两个序列完全相同时方差为0, 统计量为0
+func zStatistic(difference, variance float64) float64 {
+	if difference == 0 {
+		return 0
+	}
+	return difference / math.Sqrt(variance)
+}
+
+// neweyWestLag Newey-West估计的滞后阶数, lag <= 0时取floor(4*(n/100)^(2/9))
+func neweyWestLag(lag, n int) int {
+	if lag <= 0 {
+		lag = int(4 * math.Pow(float64(n)/100, 2.0/9))
+	}
+	if lag >= n {
+		lag = n - 1
+	}
+	return lag
+}
+
+func checkPair(name string, ra, rb []float64) error {
+	if len(ra) != len(rb) {
+		return errors.New("In " + name + ", len(ra) != len(rb)")
+	}
+	if len(ra) < 3 {
+		return errors.New("In " + name + ", not enough samples")
+	}
+	return nil
+}
+
+// JobsonKorkieTest 对两个对齐的超额收益率序列做Jobson-Korkie检验
+func JobsonKorkieTest(ra, rb []float64) (SharpeTest, error) {
+	if err := checkPair("JobsonKorkieTest", ra, rb); err != nil {
+		return SharpeTest{}, err
+	}
+	n := float64(len(ra))
+	ma, mb := Vector(ra).Average(), Vector(rb).Average()
+	var va, vb, cov float64
+	for i := range ra {
+		va += (ra[i] - ma) * (ra[i] - ma)
+		vb += (rb[i] - mb) * (rb[i] - mb)
+		cov += (ra[i] - ma) * (rb[i] - mb)
+	}
+	va, vb, cov = va/(n-1), vb/(n-1), cov/(n-1)
+	sa, sb := math.Sqrt(va), math.Sqrt(vb)
+	theta := (2*va*vb - 2*sa*sb*cov + ma*ma*vb/2 + mb*mb*va/2 - ma*mb/(2*sa*sb)*(cov*cov+va*vb)) / n
+	return newSharpeTest(ma, mb, sa, sb, zStatistic(sb*ma-sa*mb, theta)), nil
+}
+
+// LedoitWolfTest 对两个对齐的超额收益率序列做Ledoit-Wolf检验
+// lag为Newey-West估计的滞后阶数, <= 0时取floor(4*(n/100)^(2/9))
+func LedoitWolfTest(ra, rb []float64, lag int) (SharpeTest, error) {
+	if err := checkPair("LedoitWolfTest", ra, rb); err != nil {
+		return SharpeTest{}, err
+	}
+	length := len(ra)
+	n := float64(length)
+	lag = neweyWestLag(lag, length)
+	// 以均值与二阶原点矩表示夏普比率, 按delta方法得到差的方差
+	ma, mb := Vector(ra).Average(), Vector(rb).Average()
+	var ga, gb float64
+	for i := range ra {
+		ga += ra[i] * ra[i]
+		gb += rb[i] * rb[i]
+	}
+	ga, gb = ga/n, gb/n
+	da, db := ga-ma*ma, gb-mb*mb
+	gradient := [4]float64{
+		ga / math.Pow(da, 1.5),
+		-gb / math.Pow(db, 1.5),
+		-ma / (2 * math.Pow(da, 1.5)),
+		mb / (2 * math.Pow(db, 1.5)),
+	}
+	y := make([][4]float64, length)
+	for i := range ra {
+		y[i] = [4]float64{ra[i] - ma, rb[i] - mb, ra[i]*ra[i] - ga, rb[i]*rb[i] - gb}
+	}
+	// Newey-West, Bartlett核
+	var psi [4][4]float64
+	for j := 0; j <= lag; j++ {
+		weight := 1.0
+		if j > 0 {
+			weight = 1 - float64(j)/float64(lag+1)
+		}
+		for t := j; t < length; t++ {
+			for k := 0; k < 4; k++ {
+				for l := 0; l < 4; l++ {
+					value := y[t][k] * y[t-j][l]
+					if j > 0 {
+						value += y[t-j][k] * y[t][l]
+					}
+					psi[k][l] += weight * value / n
+				}
+			}
+		}
+	}
+	variance := 0.0
+	for k := 0; k < 4; k++ {
+		for l := 0; l < 4; l++ {
+			variance += gradient[k] * psi[k][l] * gradient[l]
+		}
+	}
+	sa, sb := math.Sqrt(da), math.Sqrt(db)
+	return newSharpeTest(ma, mb, sa, sb, zStatistic(ma/sa-mb/sb, variance/n)), nil
+}
+
+// CompareSharpe 检验两个计算器中组合的夏普比率是否相等, 两者的收益率需要按日期对齐
+// Ledoit-Wolf检验的滞后阶数自动选择
+func CompareSharpe(a, b MetricCalculator, method SharpeTestMethod) (SharpeTest, error) {
+	ra, rb := a.excessRatio("Portfolio", a.PortfolioRatio()), b.excessRatio("Portfolio", b.PortfolioRatio())
+	switch method {
+	case SharpeTestJobsonKorkie:
+		return JobsonKorkieTest(ra, rb)
+	case SharpeTestLedoitWolf:
+		return LedoitWolfTest(ra, rb, 0)
+	default:
+		return SharpeTest{}, fmt.Errorf("In CompareSharpe, unknown method %d", method)
+	}
+}
@@ -0,0 +1,302 @@
+package metric
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// moments 按Welford方法维护的均值以及二、三、四阶中心矩之和, 支持移除观测
+type moments struct {
+	n, mean, m2, m3, m4 float64
+}
+
+func (m *moments) add(x float64) {
+	n1 := m.n
+	m.n++
+	n := m.n
+	delta := x - m.mean
+	deltaN := delta / n
+	deltaN2 := deltaN * deltaN
+	term := delta * deltaN * n1
+	m.mean += deltaN
+	m.m4 += term*deltaN2*(n*n-3*n+3) + 6*deltaN2*m.m2 - 4*deltaN*m.m3
+	m.m3 += term*deltaN*(n-2) - 3*deltaN*m.m2
+	m.m2 += term
+}
+
+// remove add的逆运算
+func (m *moments) remove(x float64) {
+	n := m.n
+	if n <= 1 {
+		*m = moments{}
+		return
+	}
+	n1 := n - 1
+	mean := (n*m.mean - x) / n1
+	delta := x - mean
+	m2 := m.m2 - delta*delta*n1/n
+	m3 := m.m3 - delta*delta*delta*n1*(n1-1)/(n*n) + 3*delta*m2/n
+	m4 := m.m4 - delta*delta*delta*delta*n1*(n1*n1-n1+1)/(n*n*n) - 6*delta*delta*m2/(n*n) + 4*delta*m3/n
+	*m = moments{n: n1, mean: mean, m2: m2, m3: m3, m4: m4}
+}
+
+// comoment 两个序列的协方差之和, 用于回归
+type comoment struct {
+	n, meanX, meanY, c float64
+}
+
+func (m *comoment) add(x, y float64) {
+	m.n++
+	dx := x - m.meanX
+	m.meanX += dx / m.n
+	m.meanY += (y - m.meanY) / m.n
+	m.c += dx * (y - m.meanY)
+}
+
+func (m *comoment) remove(x, y float64) {
+	n := m.n
+	if n <= 1 {
+		*m = comoment{}
+		return
+	}
+	meanX := (n*m.meanX - x) / (n - 1)
+	m.c -= (x - meanX) * (y - m.meanY)
+	m.meanY = (n*m.meanY - y) / (n - 1)
+	m.meanX = meanX
+	m.n = n - 1
+}
+
+// StreamingCalculator 逐个追加观测的计算器, 每次追加在O(1)内更新收益率的各阶矩、
+// 回撤以及回归所需的和, 任何已注册的指标都可以随时读取
+// window > 0时只保留最近window个观测, 相当于一个内存有界的滑动窗口
+// 可以被多个goroutine并发使用
+type StreamingCalculator struct {
+	mu               sync.Mutex
+	config           Config
+	window           int
+	hasBench         bool
+	portfolio, bench []float64
+	dates            []time.Time
+	pm, bm           moments
+	pb               comoment
+	logSum           float64
+	nonPositive      int
+	peak, drawdown   float64
+	snapshot         *MetricCalculator
+}
+
+// NewStreamingCalculator 创建流式计算器, window <= 0时保留全部数据, config缺省时使用DefaultConfig
+func NewStreamingCalculator(window int, config ...Config) *StreamingCalculator {
+	cfg := DefaultConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if window < 0 {
+		window = 0
+	}
+	cfg.Method = normalizeMethod(cfg.Method)
+	return &StreamingCalculator{config: cfg, window: window}
+}
+
+func (s *StreamingCalculator) Config() Config {
+	return s.config
+}
+
+// Len 当前保留的观测个数
+func (s *StreamingCalculator) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.portfolio)
+}
+
+func ratioOf(prev, value float64) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return value/prev - 1
+}
+
+// Append 追加一个观测, 第一个观测的benchValue为NaN时该计算器没有基准, 之后的benchValue被忽略
+func (s *StreamingCalculator) Append(value, benchValue float64, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = nil
+	n := len(s.portfolio)
+	if n == 0 {
+		s.hasBench = !math.IsNaN(benchValue)
+		s.peak = value
+		s.drawdown = value/s.peak - 1.0
+		s.addRatio(0, 0)
+	} else {
+		b := 0.0
+		if s.hasBench {
+			b = ratioOf(s.bench[n-1], benchValue)
+		}
+		s.addRatio(ratioOf(s.portfolio[n-1], value), b)
+		if value > s.peak {
+			s.peak = value
+		} else if dd := value/s.peak - 1.0; dd < s.drawdown {
+			s.drawdown = dd
+		}
+	}
+	s.portfolio = append(s.portfolio, value)
+	if s.hasBench {
+		s.bench = append(s.bench, benchValue)
+	}
+	s.dates = append(s.dates, t)
+	if s.window > 0 && len(s.portfolio) > s.window {
+		// 窗口的第一个收益率始终为0, 移除的是新的第一个观测对应的收益率
+		b := 0.0
+		if s.hasBench {
+			b = ratioOf(s.bench[0], s.bench[1])
+			s.bench = s.bench[1:]
+		}
+		s.removeRatio(ratioOf(s.portfolio[0], s.portfolio[1]), b)
+		s.portfolio = s.portfolio[1:]
+		s.dates = s.dates[1:]
+	}
+}
+
+func (s *StreamingCalculator) addRatio(p, b float64) {
+	s.pm.add(p)
+	if s.hasBench {
+		s.bm.add(b)
+		s.pb.add(b, p)
+	}
+	if 1+p <= 0 {
+		s.nonPositive++
+	} else {
+		s.logSum += math.Log(1 + p)
+	}
+}
+
+func (s *StreamingCalculator) removeRatio(p, b float64) {
+	s.pm.remove(p)
+	if s.hasBench {
+		s.bm.remove(b)
+		s.pb.remove(b, p)
+	}
+	if 1+p <= 0 {
+		s.nonPositive--
+	} else {
+		s.logSum -= math.Log(1 + p)
+	}
+}
+
+// Calculator 当前数据的计算器, 下一次Append之前重复调用返回同一个计算器
+func (s *StreamingCalculator) Calculator() *MetricCalculator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calculator()
+}
+
+func (s *StreamingCalculator) calculator() *MetricCalculator {
+	if s.snapshot == nil {
+		n := len(s.portfolio)
+		var bench []float64
+		if s.hasBench {
+			bench = s.bench[:n:n]
+		}
+		s.snapshot = NewMetricCalculator(s.portfolio[:n:n], bench, s.dates[:n:n], s.config)
+	}
+	return s.snapshot
+}
+
+// Process 计算指标, 结果与用当前数据创建的MetricCalculator一致
+// 不带参数时, 基于矩、回撤与回归的指标直接由累积量得到, 其余指标在当前数据上计算
+func (s *StreamingCalculator) Process(name string, params ...Params) (float64, error) {
+	s.mu.Lock()
+	if fast, exist := streamingFastMap[name]; exist && len(params) == 0 && s.config.Method == MethodDiscrete && s.config.Scale > 0 {
+		check := MetricCalculator{portfolio: s.portfolio, bench: s.bench}
+		if info, exist := MetricInfoMap[name]; exist {
+			if err := info.check(check); err != nil {
+				s.mu.Unlock()
+				return math.NaN(), err
+			}
+		}
+		if value, ok := fast(s); ok {
+			s.mu.Unlock()
+			return value, nil
+		}
+	}
+	c := s.calculator()
+	s.mu.Unlock()
+	return c.Process(name, params...)
+}
+
+// AlphaBeta 组合收益率对基准收益率回归的截距与斜率, 与AlphaBeta一致
+func (s *StreamingCalculator) AlphaBeta() (alpha, beta float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hasBench {
+		return math.NaN(), math.NaN(), errors.New("In AlphaBeta, benchmark is required")
+	}
+	beta = s.pb.c / s.bm.m2
+	return s.pm.mean - beta*s.bm.mean, beta, nil
+}
+
+// streamingFast 可以直接由累积量得到的指标, ok为false时在当前数据上完整计算
+type streamingFast func(s *StreamingCalculator) (value float64, ok bool)
+
+var streamingFastMap = map[string]streamingFast{
+	"Variance": streamingVariance,
+	"StdDev": func(s *StreamingCalculator) (float64, bool) {
+		v, ok := streamingVariance(s)
+		return math.Sqrt(v), ok
+	},
+	"StdDev_Annualized": func(s *StreamingCalculator) (float64, bool) {
+		v, ok := streamingVariance(s)
+		return math.Sqrt(s.config.Scale) * math.Sqrt(v), ok
+	},
+	"Skewness": func(s *StreamingCalculator) (float64, bool) {
+		n := s.pm.n
+		if n <= 2 {
+			return math.NaN(), false
+		}
+		return s.pm.m3 / n / math.Pow(s.pm.m2/n, 1.5), true
+	},
+	"Kurtosis": func(s *StreamingCalculator) (float64, bool) {
+		n := s.pm.n
+		if n <= 3 {
+			return math.NaN(), false
+		}
+		v := s.pm.m2 / (n - 1)
+		sum := s.pm.m4 / (v * v)
+		return sum*n*(n+1.0)/((n-1.0)*(n-2.0)*(n-3.0)) - 3*(n-1.0)*(n-1.0)/((n-2.0)*(n-3.0)), true
+	},
+	"Annualized": func(s *StreamingCalculator) (float64, bool) {
+		if s.pm.n == 0 || s.nonPositive > 0 {
+			return math.NaN(), false
+		}
+		return math.Exp(s.logSum*s.config.Scale/s.pm.n) - 1, true
+	},
+	"MeanGeometric": func(s *StreamingCalculator) (float64, bool) {
+		if s.pm.n == 0 || s.nonPositive > 0 {
+			return math.NaN(), false
+		}
+		return math.Exp(s.logSum/s.pm.n) - 1, true
+	},
+	"MaxDrawdown": func(s *StreamingCalculator) (float64, bool) {
+		// 滑动窗口中的高点会过期, 只在保留全部数据时使用累积的回撤
+		if s.window > 0 || len(s.portfolio) == 0 {
+			return math.NaN(), false
+		}
+		return -s.drawdown, true
+	},
+	"Beta": func(s *StreamingCalculator) (float64, bool) {
+		if !s.hasBench || s.pm.n <= 2 {
+			return math.NaN(), false
+		}
+		return s.pb.c / s.bm.m2, true
+	},
+}
+
+func streamingVariance(s *StreamingCalculator) (float64, bool) {
+	n := s.pm.n
+	if n <= 2 {
+		return math.NaN(), false
+	}
+	return s.pm.m2 / (n - 1.0), true
+}
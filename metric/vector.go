@@ -13,6 +13,7 @@ func (v Vector) ImplV(op func(float64) float64) Vector {
 	return v
 }
 
+// ImplVectorV 长度不一致时只处理较短的部分, 日期不同的序列应先用AlignSeries对齐
 func (v1 Vector) ImplVectorV(v2 Vector, op func(float64, float64) (float64, float64)) Vector {
 	length := len(v1)
 	if len(v2) < length {
@@ -24,13 +25,14 @@ func (v1 Vector) ImplVectorV(v2 Vector, op func(float64, float64) (float64, floa
 	return v1
 }
 
+// ReturnRatio 按method计算收益率, 第一个值为0, 未知的method返回nil
 func (v1 Vector) ReturnRatio(method string) Vector {
 	if len(v1) == 0 {
 		return nil
 	}
 	v2 := make([]float64, len(v1))
-	switch method {
-	case "discrete":
+	switch normalizeMethod(method) {
+	case MethodDiscrete:
 		v2[0] = 0
 		for i := 1; i < len(v1); i++ {
 			if v1[i-1] != 0.0 {
@@ -39,6 +41,16 @@ func (v1 Vector) ReturnRatio(method string) Vector {
 				v2[i] = 0
 			}
 		}
+	case MethodLog:
+		for i := 1; i < len(v1); i++ {
+			if v1[i-1] != 0.0 {
+				v2[i] = math.Log(v1[i] / v1[i-1])
+			}
+		}
+	case MethodDifference:
+		for i := 1; i < len(v1); i++ {
+			v2[i] = v1[i] - v1[i-1]
+		}
 	default:
 		return nil
 	}
@@ -0,0 +1,68 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/bxy09/gfstat/metric"
+)
+
+// MetricWrapper 基于metric包的实现, 保持Performance接口不变
+type MetricWrapper struct {
+	name string
+}
+
+func NewMetricWrapper(name string) *MetricWrapper {
+	return &MetricWrapper{name: name}
+}
+
+func (this *MetricWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float64, date []time.Time) (float64, error) {
+	if AssetPriceReturns == nil {
+		return math.NaN(), errors.New("The Input RA is Error !!!")
+	}
+	portfolio := metric.Series{Dates: date, Values: AssetPriceReturns}
+	var bench metric.Series
+	if AssetPriceBenchMark != nil {
+		bench = metric.Series{Dates: date, Values: AssetPriceBenchMark}
+	}
+	if _, intraday := detectPeriod(date); intraday {
+		// 组合与基准各自整理为日线, 两者跳过的NaN分钟可能不同, 之后按日期对齐
+		var err error
+		for _, series := range []*metric.Series{&portfolio, &bench} {
+			if series.Values == nil {
+				continue
+			}
+			series.Values, series.Dates, err = resampleDaily(date, series.Values)
+			if err != nil {
+				return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
+			}
+		}
+	}
+	p, b, dates, err := alignInput(portfolio, bench)
+	if err != nil {
+		return math.NaN(), err
+	}
+	// Scale为0时年化因子由metric按日历从日期识别
+	config := metric.DefaultConfig()
+	config.Scale = 0
+	calculator := metric.NewMetricCalculator(p, b, dates, config)
+	return calculator.Process(this.name)
+}
+
+// alignInput 按日期对齐组合与基准, 价格中的NaN视为缺失的观测, 用前一个有效值填充, 开头的NaN被丢弃
+// 没有日期时无法对齐, 存在NaN则返回错误
+func alignInput(portfolio, bench metric.Series) ([]float64, []float64, []time.Time, error) {
+	if portfolio.Dates == nil {
+		for _, values := range [][]float64{portfolio.Values, bench.Values} {
+			for _, v := range values {
+				if math.IsNaN(v) {
+					return nil, nil, nil, errors.New("In MetricWrapper, dates are required to fill NaN")
+				}
+			}
+		}
+		return portfolio.Values, bench.Values, nil, nil
+	}
+	p, b, dates, _, err := metric.AlignSeries(portfolio, bench, metric.AlignForwardFill)
+	return p, b, dates, err
+}
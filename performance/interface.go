@@ -1,8 +1,10 @@
 // 是在未来将会废弃的基于天收益序列计算指标的模块，由于大量不恰当的使用了sliding window，导致性能不佳
+// PerformanceMap 已经由metric包实现，原有实现保留在LegacyPerformanceMap中
 package performance
 
 import (
 	"errors"
+	"github.com/bxy09/gfstat/metric"
 	"github.com/bxy09/gfstat/performance/utils"
 	"math"
 	"time"
@@ -19,62 +21,33 @@ type Func2Sliding1F func(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow, param
 type Func2Sliding2F func(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow, param1 float64, param2 float64) (float64, error)
 type Func2Sliding2F1S func(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow, param1 float64, param2 float64, str string) (float64, error)
 
-func getPeriod(recordDate []time.Time) float64 {
-	//to estimate the period
-	count_OneMinute := 0
-	count_OneDay := 0
-	count_OneMonth := 0
-	count_OneSeason := 0
-	for i := 1; i < len(recordDate); i++ {
-		diff := recordDate[i].Sub(recordDate[i-1])
-		//fmt.Println(diff.Hours(), diff.Minutes(), diff.Seconds())
-		if diff.Hours() >= 23.0 && diff.Hours() < 48.0 {
-			count_OneDay++
-		}
-		if diff.Minutes() >= 1.0 && diff.Minutes() <= 5.0 {
-			count_OneMinute++
-		}
-		if diff.Hours() >= 28.0*24.0 && diff.Hours() <= 35.0*24.0 {
-			count_OneMonth++
-		}
-		if diff.Hours() >= 3.8*30.0*24.0 && diff.Hours() <= 4.2*30.0*24.0 {
-			count_OneSeason++
-		}
-	}
-	//count the percent of the total length
-	len_Date := len(recordDate) - 1
-	Minute_Ratio := float64(count_OneMinute) / float64(len_Date)
-	Day_Ratio := float64(count_OneDay) / float64(len_Date)
-	Month_Ratio := float64(count_OneMonth) / float64(len_Date)
-	Season_Ratio := float64(count_OneSeason) / float64(len_Date)
-	if Minute_Ratio > Day_Ratio && Minute_Ratio > Month_Ratio && Minute_Ratio > Season_Ratio {
-		return 2520.0
-	}
-	if Day_Ratio > Minute_Ratio && Day_Ratio > Month_Ratio && Day_Ratio > Season_Ratio {
-		return 252.0
-	}
-	if Month_Ratio > Minute_Ratio && Month_Ratio > Day_Ratio && Month_Ratio > Season_Ratio {
-		return 12.0
-	}
-	if Season_Ratio > Minute_Ratio && Season_Ratio > Day_Ratio && Season_Ratio > Month_Ratio {
-		return 4.0
+// detectPeriod 按交易日历识别观测频率并给出年化因子, 与metric.MetricCalculator.Period一致
+// 分钟级的观测需要先整理为日线, 此时intraday为true, 年化因子为日频的年化因子
+func detectPeriod(recordDate []time.Time) (period float64, intraday bool) {
+	calendar := metric.WeekdayCalendar{}
+	frequency := metric.DetectFrequency(recordDate, calendar)
+	if frequency == metric.FrequencyMinute {
+		return calendar.PeriodsPerYear(metric.FrequencyDaily), true
 	}
-	return 252.0
+	return calendar.PeriodsPerYear(frequency), false
 }
 
 func reorganizeInputPrice(date []time.Time, MinutesPrice []float64) ([]float64, error) {
+	Price, _, err := resampleDaily(date, MinutesPrice)
+	return Price, err
+}
+
+// resampleDaily 按沪深A股的交易时段将分钟价格转换为日收盘价以及对应的收盘时间
+func resampleDaily(date []time.Time, MinutesPrice []float64) ([]float64, []time.Time, error) {
 	if len(date) != len(MinutesPrice) {
-		return nil, errors.New("The length of date and Minutes Price is not Equal !!!")
+		return nil, nil, errors.New("The length of date and Minutes Price is not Equal !!!")
 	}
-	Price := make([]float64, 0)
-	for i := 1; i < len(date); i++ {
-		diff := date[i].Sub(date[i-1])
-		if diff.Hours() > 10.0 {
-			Price = append(Price, MinutesPrice[i-1])
-		}
+	bars, err := utils.ExchangeAShare.Resample(date, MinutesPrice, utils.BarDaily)
+	if err != nil {
+		return nil, nil, err
 	}
-	Price = append(Price, MinutesPrice[len(date)-1])
-	return Price, nil
+	days, Price := utils.Closes(bars)
+	return Price, days, nil
 }
 
 type Performance interface {
@@ -113,8 +86,7 @@ func (this *P1SWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float64
 		return math.NaN(), errors.New("The Input RA is Error !!!")
 	}
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	if _, intraday := detectPeriod(date); intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
@@ -140,13 +112,12 @@ func (this *P1S1FWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float
 		return math.NaN(), errors.New("The Input RA is Error !!!")
 	}
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 	if this.param == 252 {
 		this.param = Period
@@ -174,13 +145,12 @@ func (this *P1S1F1BWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []flo
 		return math.NaN(), errors.New("The Input RA is Error !!!")
 	}
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 	if this.param == 252 {
 		this.param = Period
@@ -209,13 +179,12 @@ func (this *P1S2FWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float
 	}
 
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 
 	if this.param2 == 252 {
@@ -253,13 +222,12 @@ func (this *P1S1F1SWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []flo
 		return math.NaN(), errors.New("The Input RA is Error !!!")
 	}
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 
 	if this.param == 252 {
@@ -311,14 +279,12 @@ func (this *P2SWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float64
 	}
 
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	if _, intraday := detectPeriod(date); intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		AssetPriceBenchMark, err = reorganizeInputPrice(date, AssetPriceBenchMark)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 
 	Price, err := utils.NewSlidingWindow(len(AssetPriceReturns))
@@ -353,14 +319,13 @@ func (this *P2S1FWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float
 	}
 
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		AssetPriceBenchMark, err = reorganizeInputPrice(date, AssetPriceBenchMark)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 
 	if this.param == 252 {
@@ -403,14 +368,13 @@ func (this *P2S2FWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []float
 	}
 
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		AssetPriceBenchMark, err = reorganizeInputPrice(date, AssetPriceBenchMark)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 
 	if this.param1 == 252 {
@@ -460,14 +424,13 @@ func (this *P2S2F1SWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []flo
 	}
 
 	var err error
-	Period := getPeriod(date)
-	if Period == 2520 {
+	Period, intraday := detectPeriod(date)
+	if intraday {
 		AssetPriceReturns, err = reorganizeInputPrice(date, AssetPriceReturns)
 		AssetPriceBenchMark, err = reorganizeInputPrice(date, AssetPriceBenchMark)
 		if err != nil {
 			return math.NaN(), errors.New("Reorganize Minutes Price Error !!!")
 		}
-		Period = 252
 	}
 	if this.param1 == 252 {
 		this.param1 = Period
@@ -510,8 +473,12 @@ func (this *P2S2F1SWrapper) Process(AssetPriceReturns, AssetPriceBenchMark []flo
 	return this.function(Ra, Rb, this.param1, this.param2, this.str)
 }
 
+// PerformanceMap 由metric包实现的指标, 包含metric.MetricMap中的全部指标
 var PerformanceMap map[string]Performance
 
+// LegacyPerformanceMap 基于SlidingWindow的原有实现, 仅用于迁移期间核对结果
+var LegacyPerformanceMap map[string]Performance
+
 func init() {
 	/*
 		Params:scale
@@ -525,7 +492,7 @@ func init() {
 	Rf := 0.03
 	Scale := 252.0
 
-	PerformanceMap = map[string]Performance{
+	LegacyPerformanceMap = map[string]Performance{
 		//One utils.SlidingWindow
 		"BernardoLedoitRatio":   &P1SWrapper{BernardoLedoitRatio},   //
 		"DRatio":                &P1SWrapper{DRatio},                //
@@ -573,6 +540,7 @@ func init() {
 		"ActivePremium":    &P2S1FWrapper{ActivePremium, Scale},    //
 		"TrackingError":    &P2S1FWrapper{TrackingError, Scale},    //
 		"InformationRatio": &P2S1FWrapper{InformationRatio, Scale}, //
+		"Beta":             &P2S1FWrapper{Beta2, Rf},               //
 
 		"M2Sortino":         &P2S2FWrapper{M2Sortino, Scale, MAR},         //
 		"FamaBeta":          &P2S2FWrapper{FamaBeta, Scale, Scale},        //
@@ -583,8 +551,14 @@ func init() {
 		"OmegaExcessReturn": &P2S2FWrapper{OmegaExcessReturn, Scale, MAR}, //
 		"MSquared":          &P2S2FWrapper{MSquared, Scale, Rf},           //
 		"JensenAlpha2":      &P2S2FWrapper{JensenAlpha2, Rf, Scale},       //
+		"Selectivity":       &P2S2FWrapper{Selectivity, Scale, Rf},        //
 
 		"AppraisalRatio": &P2S2F1SWrapper{AppraisalRatio, Scale, Rf, "modified"},   //
 		"MSquaredExcess": &P2S2F1SWrapper{MSquaredExcess, Scale, Rf, "arithmetic"}, //
 	}
+
+	PerformanceMap = map[string]Performance{}
+	for name := range metric.MetricMap {
+		PerformanceMap[name] = NewMetricWrapper(name)
+	}
 }
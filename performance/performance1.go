@@ -37,10 +37,10 @@ func DRatio(Ra *utils.SlidingWindow) (float64, error) {
 	downList, _ := utils.NewSlidingWindow(Ra.Count())
 
 	for i := 0; i < Ra.Count(); i++ {
-		if Ra.Data()[i] < 0 {
-			downList.Add(Ra.Data()[i])
-		} else if Ra.Data()[i] > 0 {
-			upList.Add(Ra.Data()[i])
+		if Ra.At(i) < 0 {
+			downList.Add(Ra.At(i))
+		} else if Ra.At(i) > 0 {
+			upList.Add(Ra.At(i))
 		}
 	}
 
@@ -100,7 +100,7 @@ func Variance(Ra *utils.SlidingWindow) (float64, error) {
 	result := 0.0
 	mean := Ra.Average()
 	for i := 0; i < Ra.Count(); i++ {
-		result += (Ra.Data()[i] - mean) * (Ra.Data()[i] - mean)
+		result += (Ra.At(i) - mean) * (Ra.At(i) - mean)
 	}
 	return result / (float64)(Ra.Count()-1), nil
 }
@@ -373,8 +373,8 @@ func Kappa(Ra *utils.SlidingWindow, MAR float64, l float64) (float64, error) {
 		return math.NaN(), err
 	}
 	for i := 0; i < Ra.Count(); i++ {
-		if Ra.Data()[i] < MAR {
-			undervalues.Add(Ra.Data()[i])
+		if Ra.At(i) < MAR {
+			undervalues.Add(Ra.At(i))
 		}
 	}
 
@@ -413,7 +413,7 @@ func BurkeRatio(Ra *utils.SlidingWindow, Rf float64, scale float64) (float64, er
 		return math.NaN(), err
 	}
 	for i := 1; i < len; i++ {
-		if Ra.Data()[i] < 0 {
+		if Ra.At(i) < 0 {
 			if !in_drawdown {
 				peak = i - 1
 				in_drawdown = true
@@ -422,7 +422,7 @@ func BurkeRatio(Ra *utils.SlidingWindow, Rf float64, scale float64) (float64, er
 			if in_drawdown {
 				temp = 1.0
 				for j := peak + 1; j < i; j++ {
-					temp = temp * (1.0 + Ra.Data()[j])
+					temp = temp * (1.0 + Ra.At(j))
 				}
 				drawdown.Add(temp - 1.0) //Source
 				in_drawdown = false
@@ -433,7 +433,7 @@ func BurkeRatio(Ra *utils.SlidingWindow, Rf float64, scale float64) (float64, er
 	if in_drawdown {
 		temp = 1.0
 		for j := peak + 1; j < len; j++ {
-			temp = temp * (1.0 + Ra.Data()[j])
+			temp = temp * (1.0 + Ra.At(j))
 		}
 		drawdown.Add(temp - 1.0) //Source
 		//drawdown.Add((temp - 1.0) * 100.0)
@@ -53,9 +53,9 @@ func DownsideDeviation(Ra *utils.SlidingWindow, MAR *utils.SlidingWindow) (float
 	len := 0.0
 	result := 0.0
 	for i := 0; i < Ra.Count(); i++ {
-		if Ra.Data()[i] < MAR.Data()[i] {
-			r.Add(Ra.Data()[i])
-			newMAR.Add(MAR.Data()[i])
+		if Ra.At(i) < MAR.At(i) {
+			r.Add(Ra.At(i))
+			newMAR.Add(MAR.At(i))
 		}
 	}
 
@@ -107,7 +107,7 @@ func DownsideFrequency(Ra *utils.SlidingWindow, MAR *utils.SlidingWindow) (float
 	}
 	len := 0.0
 	for i := 0; i < Ra.Count(); i++ {
-		if Ra.Data()[i] < MAR.Data()[i] {
+		if Ra.At(i) < MAR.At(i) {
 			len++
 		}
 	}
@@ -423,9 +423,9 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] > 0 {
-					UpRa.Add(Ra.Data()[i])
-					UpRb.Add(Rb.Data()[i])
+				if Rb.At(i) > 0 {
+					UpRa.Add(Ra.At(i))
+					UpRb.Add(Rb.At(i))
 				}
 			}
 			cumRa = UpRa.Sum()
@@ -444,9 +444,9 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] <= 0 {
-					DnRa.Add(Ra.Data()[i])
-					DnRb.Add(Rb.Data()[i])
+				if Rb.At(i) <= 0 {
+					DnRa.Add(Ra.At(i))
+					DnRb.Add(Rb.At(i))
 				}
 			}
 			cumRa = DnRa.Sum()
@@ -472,13 +472,13 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Ra.Data()[i] > 0 && Rb.Data()[i] > 0 {
-					UpRa.Add(Ra.Data()[i])
+				if Ra.At(i) > 0 && Rb.At(i) > 0 {
+					UpRa.Add(Ra.At(i))
 				}
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] > 0 {
-					UpRb.Add(Rb.Data()[i])
+				if Rb.At(i) > 0 {
+					UpRb.Add(Rb.At(i))
 				}
 			}
 
@@ -498,13 +498,13 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Ra.Data()[i] < 0 && Rb.Data()[i] < 0 {
-					DnRa.Add(Ra.Data()[i])
+				if Ra.At(i) < 0 && Rb.At(i) < 0 {
+					DnRa.Add(Ra.At(i))
 				}
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] < 0 {
-					DnRb.Add(Rb.Data()[i])
+				if Rb.At(i) < 0 {
+					DnRb.Add(Rb.At(i))
 				}
 			}
 
@@ -531,13 +531,13 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Ra.Data()[i] > Rb.Data()[i] && Rb.Data()[i] > 0 {
-					UpRa.Add(Ra.Data()[i])
+				if Ra.At(i) > Rb.At(i) && Rb.At(i) > 0 {
+					UpRa.Add(Ra.At(i))
 				}
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] > 0 {
-					UpRb.Add(Rb.Data()[i])
+				if Rb.At(i) > 0 {
+					UpRb.Add(Rb.At(i))
 				}
 			}
 
@@ -557,13 +557,13 @@ func UpDownRatios(Ra *utils.SlidingWindow, Rb *utils.SlidingWindow) (float64, er
 				return math.NaN(), err
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Ra.Data()[i] > Rb.Data()[i] && Rb.Data()[i] < 0 {
-					DnRa.Add(Ra.Data()[i])
+				if Ra.At(i) > Rb.At(i) && Rb.At(i) < 0 {
+					DnRa.Add(Ra.At(i))
 				}
 			}
 			for i := 0; i < Ra.Count(); i++ {
-				if Rb.Data()[i] < 0 {
-					DnRb.Add(Rb.Data()[i])
+				if Rb.At(i) < 0 {
+					DnRb.Add(Rb.At(i))
 				}
 			}
 
@@ -109,3 +109,108 @@ func Test_Same_Data(t *testing.T) {
 		fmt.Println(key, " Result: ", result, "Error: ", err)
 	}
 }
+
+func Test_Metric_Identity(t *testing.T) {
+	// test_Same_Data.csv中组合净值不变, 收益率的方差与beta都是0, 以下指标没有定义
+	// 原有实现的SlidingWindow把0/0得到的NaN记为0, beta取回归的舍入误差, metric给出NaN或-Inf
+	undefined := map[string]float64{
+		"Skewness":              math.NaN(),
+		"Kurtosis":              math.NaN(),
+		"SkewnessKurtosisRatio": math.NaN(),
+		"TreynorRatio":          math.Inf(-1),
+		"AppraisalRatio":        math.Inf(-1),
+	}
+	for _, filename := range []string{"testData/555665746e955230ea000001_profit_table.csv", "testData/test_Same_Data.csv"} {
+		data, bench_mark, date := ReadFromCSV(filename)
+		for key, legacy := range LegacyPerformanceMap {
+			expected, expectedErr := legacy.Process(data, bench_mark, date)
+			result, err := PerformanceMap[key].Process(data, bench_mark, date)
+			if expectedErr == nil && err != nil {
+				t.Fatal(filename, key, err)
+			}
+			if want, ok := undefined[key]; ok && filename == "testData/test_Same_Data.csv" {
+				if !closeEnough(want, result) {
+					t.Fatal("undefined metric of constant portfolio", key, want, result)
+				}
+				continue
+			}
+			if !closeEnough(expected, result) {
+				t.Fatal("not identity", filename, key, expected, result)
+			}
+		}
+	}
+}
+
+// closeEnough 按相对误差比较, 两者都是NaN或相等的Inf时视为一致
+func closeEnough(expect, value float64) bool {
+	if math.IsNaN(expect) || math.IsNaN(value) {
+		return math.IsNaN(expect) && math.IsNaN(value)
+	}
+	if expect == value {
+		return true
+	}
+	return math.Abs(expect-value) <= 0.000001*math.Max(1, math.Abs(expect))
+}
+
+func Test_Metric_NaN(t *testing.T) {
+	data, bench_mark, date := ReadFromCSV("testData/555665746e955230ea000001_profit_table.csv")
+	withNaN := append([]float64(nil), data...)
+	withNaN[10] = math.NaN()
+	filled := append([]float64(nil), data...)
+	filled[10] = filled[9]
+	for _, key := range []string{"SharpeRatio", "MaxDrawdown", "Beta"} {
+		expected, _ := PerformanceMap[key].Process(filled, bench_mark, date)
+		result, err := PerformanceMap[key].Process(withNaN, bench_mark, date)
+		if err != nil || result != expected {
+			t.Fatal("NaN should be forward filled", key, expected, result, err)
+		}
+	}
+	if _, err := PerformanceMap["SharpeRatio"].Process(withNaN, bench_mark, nil); err == nil {
+		t.Fatal("NaN without dates should fail")
+	}
+}
+
+func Test_Metric_Intraday_NaN(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	var date []time.Time
+	var data, bench_mark []float64
+	var closes, benchCloses []float64
+	v1, v2 := 100.0, 10.0
+	for day := 1; day <= 5; day++ {
+		for _, session := range [][2]int{{570, 690}, {780, 900}} {
+			for m := session[0] + 1; m <= session[1]; m++ {
+				date = append(date, time.Date(2015, 6, day, 0, m, 0, 0, cst))
+				v1 += 0.01 * float64(m%7-3)
+				v2 += 0.001*float64(m%5-2) + 0.0001*float64(day%3-1)
+				if day == 3 {
+					// 组合在第3天没有任何有效的价格, 整理为日线后比基准少一天
+					data = append(data, math.NaN())
+				} else {
+					data = append(data, v1)
+				}
+				bench_mark = append(bench_mark, v2)
+			}
+		}
+		if day == 3 {
+			closes = append(closes, closes[len(closes)-1])
+		} else {
+			closes = append(closes, v1)
+		}
+		benchCloses = append(benchCloses, v2)
+	}
+	bars, err := utils.ExchangeAShare.Resample(date, bench_mark, utils.BarDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days, _ := utils.Closes(bars)
+	for _, key := range []string{"SharpeRatio", "Beta"} {
+		expected, err := PerformanceMap[key].Process(closes, benchCloses, days)
+		if err != nil {
+			t.Fatal(key, err)
+		}
+		result, err := PerformanceMap[key].Process(data, bench_mark, date)
+		if err != nil || math.Abs(result-expected) > 1e-12*math.Max(1, math.Abs(expected)) {
+			t.Fatal("minute prices should be resampled and aligned", key, expected, result, err)
+		}
+	}
+}
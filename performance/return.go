@@ -63,10 +63,9 @@ func Calculate(prices *utils.SlidingWindow, method string) (*utils.SlidingWindow
 	}
 
 	switch method {
-	case "simple":
-	case "discrete":
+	case "simple", "discrete":
 		for i := 0; i < prices.Count(); i++ {
-			price := prices.Data()[i]
+			price := prices.At(i)
 			if lastPrice != 0.0 {
 				returns.Add(price/lastPrice - 1.0)
 			} else {
@@ -74,10 +73,9 @@ func Calculate(prices *utils.SlidingWindow, method string) (*utils.SlidingWindow
 			}
 			lastPrice = price
 		}
-	case "compound":
-	case "log":
+	case "compound", "log":
 		for i := 0; i < prices.Count(); i++ {
-			price := prices.Data()[i]
+			price := prices.At(i)
 			if lastPrice != 0.0 {
 				returns.Add(math.Log(price / lastPrice))
 			} else {
@@ -85,6 +83,12 @@ func Calculate(prices *utils.SlidingWindow, method string) (*utils.SlidingWindow
 			}
 			lastPrice = price
 		}
+	case "difference":
+		for i := 0; i < prices.Count(); i++ {
+			price := prices.At(i)
+			returns.Add(price - lastPrice)
+			lastPrice = price
+		}
 	default:
 		return nil, errors.New("The input Method is nil !!!")
 	}
@@ -104,7 +108,7 @@ func Excess(returns, Rf *utils.SlidingWindow) (*utils.SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < returns.Count(); i++ {
-		result.Add(returns.Data()[i] - Rf.Data()[i])
+		result.Add(returns.At(i) - Rf.At(i))
 	}
 	return result, nil
 }
@@ -120,8 +124,8 @@ func Relative(Ra, Rb *utils.SlidingWindow) (*utils.SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < Ra.Count(); i++ {
-		res4Ra = res4Ra * (1 + Ra.Data()[i])
-		res4Rb = res4Rb * (1 + Rb.Data()[i])
+		res4Ra = res4Ra * (1 + Ra.At(i))
+		res4Rb = res4Rb * (1 + Rb.At(i))
 		result.Add(res4Ra / res4Rb)
 	}
 	return result, nil
@@ -1,18 +1,90 @@
 package utils
 
-import "time"
+import (
+	"errors"
+	"sync"
+	"time"
+)
 
-//DayInBJ 时间对应的北京时间日期序号
+var beijing = time.FixedZone("CST", 8*3600)
+
+// exchangeZones 交易所代码对应的时区
+var exchangeZones = map[string]string{
+	"SSE":      "Asia/Shanghai",
+	"SZSE":     "Asia/Shanghai",
+	"SHFE":     "Asia/Shanghai",
+	"DCE":      "Asia/Shanghai",
+	"CZCE":     "Asia/Shanghai",
+	"CFFEX":    "Asia/Shanghai",
+	"HKEX":     "Asia/Hong_Kong",
+	"NYSE":     "America/New_York",
+	"NASDAQ":   "America/New_York",
+	"CME":      "America/Chicago",
+	"LSE":      "Europe/London",
+	"XETRA":    "Europe/Berlin",
+	"EURONEXT": "Europe/Paris",
+}
+
+var locations sync.Map
+
+// LocationOf 交易所代码对应的时区, 沪深及国内期货交易所直接使用UTC+8
+func LocationOf(exchange string) (*time.Location, error) {
+	name, exist := exchangeZones[exchange]
+	if !exist {
+		return nil, errors.New("In LocationOf, unknown exchange " + exchange)
+	}
+	if name == "Asia/Shanghai" {
+		return beijing, nil
+	}
+	if loc, exist := locations.Load(name); exist {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// DayIn 时间在loc中的日期序号, 即自1970-01-01起的天数, 与夏令时无关
+func DayIn(t time.Time, loc *time.Location) int64 {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 3600)
+}
+
+// StartOfDay 获取t在loc中当天的零点, 夏令时切换的当天不一定是24小时
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// StartOfDayI 从日期序号获得loc中的零点
+func StartOfDayI(day int64, loc *time.Location) time.Time {
+	y, m, d := time.Unix(day*24*3600, 0).UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// DayOnExchange 时间在交易所所在时区的日期序号
+func DayOnExchange(t time.Time, exchange string) (int64, error) {
+	loc, err := LocationOf(exchange)
+	if err != nil {
+		return 0, err
+	}
+	return DayIn(t, loc), nil
+}
+
+// DayInBJ 时间对应的北京时间日期序号
 func DayInBJ(t time.Time) int64 {
-	return (t.Unix() + 8*3600) / (24 * 3600)
+	return DayIn(t, beijing)
 }
 
-//StartOfBJTime 获取当天北京时间零点
+// StartOfBJTime 获取当天北京时间零点
 func StartOfBJTime(t time.Time) time.Time {
-	return time.Unix(DayInBJ(t)*24*3600-8*3600, 0)
+	return StartOfDay(t, beijing)
 }
 
-//StartOfBJTimeI 从北京时间日期序号获得时间零点
+// StartOfBJTimeI 从北京时间日期序号获得时间零点
 func StartOfBJTimeI(day int64) time.Time {
-	return time.Unix(day*24*3600-8*3600, 0)
+	return StartOfDayI(day, beijing)
 }
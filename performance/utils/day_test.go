@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayIn(t *testing.T) {
+	ny, err := LocationOf("NYSE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2015-03-08 美东切换为夏令时, 当天只有23小时
+	before := time.Date(2015, 3, 7, 23, 30, 0, 0, ny)
+	after := time.Date(2015, 3, 8, 23, 30, 0, 0, ny)
+	if DayIn(after, ny)-DayIn(before, ny) != 1 {
+		t.Errorf("DayIn across DST: %d, %d", DayIn(before, ny), DayIn(after, ny))
+	}
+	start := StartOfDay(after, ny)
+	if start.Hour() != 0 || start.Day() != 8 || after.Sub(start) != 22*time.Hour+30*time.Minute {
+		t.Errorf("StartOfDay across DST: %s", start)
+	}
+	if !StartOfDayI(DayIn(after, ny), ny).Equal(start) {
+		t.Errorf("StartOfDayI across DST: %s", StartOfDayI(DayIn(after, ny), ny))
+	}
+	// 2015-11-01 切换回标准时间, 当天有25小时
+	end := time.Date(2015, 11, 1, 23, 59, 0, 0, ny)
+	if end.Sub(StartOfDay(end, ny)) != 24*time.Hour+59*time.Minute {
+		t.Errorf("StartOfDay at DST end: %s", StartOfDay(end, ny))
+	}
+
+	bj := time.Date(2015, 3, 8, 23, 30, 0, 0, time.UTC)
+	day, err := DayOnExchange(bj, "SSE")
+	if err != nil || day != DayInBJ(bj) || day != (bj.Unix()+8*3600)/(24*3600) {
+		t.Errorf("DayOnExchange SSE: %d, %v", day, err)
+	}
+	if !StartOfBJTime(bj).Equal(time.Unix(day*24*3600-8*3600, 0)) || !StartOfBJTimeI(day).Equal(StartOfBJTime(bj)) {
+		t.Errorf("StartOfBJTime: %s", StartOfBJTime(bj))
+	}
+	if _, err := DayOnExchange(bj, "UNKNOWN"); err == nil {
+		t.Error("DayOnExchange should fail for unknown exchange")
+	}
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Session 一个交易时段, 以交易日零点起的分钟数表示, 前一天晚上开始的夜盘为负数
+// 例如 9:30-11:30 为 {570, 690}, 21:00-次日2:30 为 {-180, 150}
+type Session struct {
+	Start, End int
+}
+
+// Exchange 交易所的交易时段定义
+type Exchange struct {
+	Name     string
+	Location *time.Location
+	// NightStart 不为0时, 当天该分钟数之后的数据属于下一个交易日的夜盘
+	NightStart int
+	Sessions   []Session // 按交易日内的时间顺序排列
+}
+
+// ExchangeAShare 沪深A股, 9:30-11:30, 13:00-15:00
+var ExchangeAShare = Exchange{
+	Name:     "SSE/SZSE",
+	Location: beijing,
+	Sessions: []Session{{570, 690}, {780, 900}},
+}
+
+// ExchangeSHFE 上期所带夜盘的品种, 21:00-次日2:30, 9:00-10:15, 10:30-11:30, 13:30-15:00
+var ExchangeSHFE = Exchange{
+	Name:       "SHFE",
+	Location:   beijing,
+	NightStart: 20 * 60,
+	Sessions:   []Session{{-180, 150}, {540, 615}, {630, 690}, {810, 900}},
+}
+
+// BarSize 重采样的目标周期, 日内周期以分钟数表示
+type BarSize int
+
+const (
+	Bar5Min   BarSize = 5
+	Bar30Min  BarSize = 30
+	Bar1Hour  BarSize = 60
+	BarDaily  BarSize = 24 * 60
+	BarWeekly BarSize = 7 * 24 * 60
+)
+
+// Bar 重采样后的一根K线
+// Time为该周期名义上的结束时间, 日内为时段内的周期结束时间, 日线为当天最后一个时段的收盘时间,
+// 周线为该周周五最后一个时段的收盘时间; Start与End为周期内第一个与最后一个观测的时间
+type Bar struct {
+	Time, Start, End       time.Time
+	Open, High, Low, Close float64
+}
+
+type barKey struct {
+	day     int64 // 交易日, 自1970-01-01起的天数
+	session int
+	bucket  int
+}
+
+// tradingDay 观测所属的交易日以及在该交易日内的分钟数, 周末的数据属于下一个周一
+func (e Exchange) tradingDay(t time.Time) (day time.Time, minute int) {
+	loc := e.Location
+	if loc == nil {
+		loc = beijing
+	}
+	t = t.In(loc)
+	day = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	minute = t.Hour()*60 + t.Minute()
+	if t.Second() > 0 || t.Nanosecond() > 0 {
+		minute++
+	}
+	if e.NightStart != 0 && minute >= e.NightStart {
+		day = day.AddDate(0, 0, 1)
+		minute -= 24 * 60
+	}
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, minute
+}
+
+// session 分钟数所在的时段, 时段之外的观测归入之后最近的时段, 收盘之后的观测归入最后一个时段
+func (e Exchange) session(minute int) int {
+	for i, s := range e.Sessions {
+		if minute <= s.End {
+			return i
+		}
+	}
+	return len(e.Sessions) - 1
+}
+
+func (e Exchange) key(t time.Time, size BarSize) (barKey, time.Time) {
+	day, minute := e.tradingDay(t)
+	last := e.Sessions[len(e.Sessions)-1]
+	switch {
+	case size >= BarWeekly:
+		friday := day.AddDate(0, 0, int(time.Friday-day.Weekday()))
+		return barKey{day: DayIn(friday, friday.Location())}, friday.Add(time.Duration(last.End) * time.Minute)
+	case size >= BarDaily:
+		return barKey{day: DayIn(day, day.Location())}, day.Add(time.Duration(last.End) * time.Minute)
+	}
+	i := e.session(minute)
+	s := e.Sessions[i]
+	offset := minute - s.Start
+	if offset < 1 {
+		offset = 1
+	}
+	if offset > s.End-s.Start {
+		offset = s.End - s.Start
+	}
+	bucket := (offset - 1) / int(size)
+	end := s.Start + (bucket+1)*int(size)
+	if end > s.End {
+		end = s.End
+	}
+	return barKey{day: DayIn(day, day.Location()), session: i, bucket: bucket}, day.Add(time.Duration(end) * time.Minute)
+}
+
+// Resample 将按时间排序的分钟价格重采样为K线, 分钟K线以结束时间标记, 例如9:31表示9:30-9:31
+// NaN被忽略, 没有有效观测的周期不产生K线
+func (e Exchange) Resample(dates []time.Time, prices []float64, size BarSize) ([]Bar, error) {
+	if len(dates) != len(prices) {
+		return nil, errors.New("In Resample, len(dates) != len(prices)")
+	}
+	if len(e.Sessions) == 0 {
+		return nil, errors.New("In Resample, exchange " + e.Name + " has no session")
+	}
+	if size <= 0 {
+		return nil, errors.New("In Resample, invalid bar size")
+	}
+	var bars []Bar
+	var current barKey
+	for i, t := range dates {
+		price := prices[i]
+		if math.IsNaN(price) {
+			continue
+		}
+		if i > 0 && t.Before(dates[i-1]) {
+			return nil, errors.New("In Resample, dates should be sorted")
+		}
+		key, label := e.key(t, size)
+		if len(bars) == 0 || key != current {
+			bars = append(bars, Bar{Time: label, Start: t, Open: price, High: price, Low: price})
+			current = key
+		}
+		bar := &bars[len(bars)-1]
+		bar.End = t
+		bar.Close = price
+		bar.High = math.Max(bar.High, price)
+		bar.Low = math.Min(bar.Low, price)
+	}
+	return bars, nil
+}
+
+// BarsPerDay 每个交易日内该周期K线的个数, 日线及以上为1
+func (e Exchange) BarsPerDay(size BarSize) int {
+	if size >= BarDaily {
+		return 1
+	}
+	count := 0
+	for _, s := range e.Sessions {
+		count += (s.End - s.Start + int(size) - 1) / int(size)
+	}
+	return count
+}
+
+// Closes K线的名义结束时间与收盘价
+func Closes(bars []Bar) ([]time.Time, []float64) {
+	dates := make([]time.Time, len(bars))
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		dates[i] = bar.Time
+		closes[i] = bar.Close
+	}
+	return dates, closes
+}
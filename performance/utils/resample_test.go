@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func minuteBars(exchange Exchange, from, to time.Time) ([]time.Time, []float64) {
+	var dates []time.Time
+	var prices []float64
+	for t := from; !t.After(to); t = t.Add(time.Minute) {
+		// 周五的夜盘持续到周六凌晨
+		if t.Weekday() == time.Sunday || (t.Weekday() == time.Saturday && t.Hour() >= 3) {
+			continue
+		}
+		_, minute := exchange.tradingDay(t)
+		for _, s := range exchange.Sessions {
+			if minute > s.Start && minute <= s.End {
+				dates = append(dates, t)
+				prices = append(prices, float64(len(prices)))
+				break
+			}
+		}
+	}
+	return dates, prices
+}
+
+func TestResample(t *testing.T) {
+	// 2015-06-05为周五
+	from := time.Date(2015, 6, 4, 0, 0, 0, 0, beijing)
+	to := time.Date(2015, 6, 8, 16, 0, 0, 0, beijing)
+	dates, prices := minuteBars(ExchangeAShare, from, to)
+	daily, err := ExchangeAShare.Resample(dates, prices, BarDaily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 3 || daily[0].Close != 239 || daily[0].Open != 0 || !daily[0].Time.Equal(time.Date(2015, 6, 4, 15, 0, 0, 0, beijing)) {
+		t.Fatalf("bad daily bars: %v", daily)
+	}
+	hourly, _ := ExchangeAShare.Resample(dates, prices, Bar1Hour)
+	if len(hourly) != 12 || ExchangeAShare.BarsPerDay(Bar1Hour) != 4 || !hourly[1].Time.Equal(time.Date(2015, 6, 4, 11, 30, 0, 0, beijing)) || hourly[1].Close != 119 {
+		t.Fatalf("bad hourly bars: %d, %v", len(hourly), hourly[1])
+	}
+	weekly, _ := ExchangeAShare.Resample(dates, prices, BarWeekly)
+	if len(weekly) != 2 || weekly[0].Close != 479 {
+		t.Fatalf("bad weekly bars: %v", weekly)
+	}
+
+	// 周五的夜盘属于下一个周一
+	dates, prices = minuteBars(ExchangeSHFE, from, to)
+	daily, _ = ExchangeSHFE.Resample(dates, prices, BarDaily)
+	perDay := 330 + 75 + 60 + 90
+	if len(daily) != 3 || daily[1].Close-daily[0].Close != float64(perDay) || !daily[2].Start.Equal(time.Date(2015, 6, 5, 21, 1, 0, 0, beijing)) {
+		t.Fatalf("bad futures daily bars: %d, %v", len(daily), daily)
+	}
+}
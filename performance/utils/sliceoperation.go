@@ -7,7 +7,7 @@ import (
 func Prod(values *SlidingWindow) (float64, error) {
 	result := 1.0
 	for i := 0; i < values.Count(); i++ {
-		result *= values.Data()[i]
+		result *= values.At(i)
 	}
 	return result, nil
 }
@@ -18,7 +18,7 @@ func Add(x float64, values *SlidingWindow) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(values.Data()[i] + x)
+		result.Add(values.At(i) + x)
 	}
 	return result, nil
 }
@@ -29,7 +29,7 @@ func Add2(values1 *SlidingWindow, values2 *SlidingWindow) (*SlidingWindow, error
 		return nil, err
 	}
 	for i := 0; i < values1.Count(); i++ {
-		result.Add(values1.Data()[i] + values2.Data()[i])
+		result.Add(values1.At(i) + values2.At(i))
 	}
 	return result, nil
 }
@@ -40,7 +40,7 @@ func Sub(values1 *SlidingWindow, values2 *SlidingWindow) (*SlidingWindow, error)
 		return nil, err
 	}
 	for i := 0; i < values1.Count(); i++ {
-		result.Add(values1.Data()[i] - values2.Data()[i])
+		result.Add(values1.At(i) - values2.At(i))
 	}
 	return result, nil
 }
@@ -51,7 +51,7 @@ func Multi(x float64, values *SlidingWindow) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(values.Data()[i] * x)
+		result.Add(values.At(i) * x)
 	}
 	return result, nil
 }
@@ -62,7 +62,7 @@ func Power(values *SlidingWindow, x float64) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(math.Pow(values.Data()[i], x))
+		result.Add(math.Pow(values.At(i), x))
 	}
 	return result, nil
 }
@@ -84,7 +84,7 @@ func Negative(values *SlidingWindow) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(-values.Data()[i])
+		result.Add(-values.At(i))
 	}
 	return result, nil
 }
@@ -95,7 +95,7 @@ func ElementMulti(values1 *SlidingWindow, values2 *SlidingWindow) (*SlidingWindo
 		return nil, err
 	}
 	for i := 0; i < values1.Count(); i++ {
-		result.Add(values1.Data()[i] * values2.Data()[i])
+		result.Add(values1.At(i) * values2.At(i))
 	}
 	return result, nil
 }
@@ -106,7 +106,7 @@ func Log(values *SlidingWindow) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(math.Log(values.Data()[i]))
+		result.Add(math.Log(values.At(i)))
 	}
 	return result, nil
 }
@@ -117,7 +117,7 @@ func Abs(values *SlidingWindow) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < values.Count(); i++ {
-		result.Add(math.Abs(values.Data()[i]))
+		result.Add(math.Abs(values.At(i)))
 	}
 	return result, nil
 }
@@ -134,10 +134,10 @@ func PosNegValues(values *SlidingWindow) (positivevalues, negativevalues *Slidin
 	}
 
 	for i := 0; i < values.Count(); i++ {
-		if values.Data()[i] > 0 {
-			positivevalues.Add(values.Data()[i])
+		if values.At(i) > 0 {
+			positivevalues.Add(values.At(i))
 		} else {
-			negativevalues.Add(values.Data()[i])
+			negativevalues.Add(values.At(i))
 		}
 	}
 	return
@@ -149,8 +149,8 @@ func AboveValue(Ra *SlidingWindow, v float64) (*SlidingWindow, error) {
 		return nil, err
 	}
 	for i := 0; i < Ra.Count(); i++ {
-		if Ra.Data()[i] > v {
-			r.Add(Ra.Data()[i])
+		if Ra.At(i) > v {
+			r.Add(Ra.At(i))
 		}
 	}
 	return r, nil
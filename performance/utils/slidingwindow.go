@@ -5,105 +5,161 @@ import (
 	"math"
 )
 
+// SlidingWindow 固定长度的滑动窗口, Sum、Average、StdDev、Max、Min均为常数时间
 type SlidingWindow struct {
 	window    []float64
 	index     int
 	count     int
 	container int
+	seq       int // 已加入的观测总数, 第seq个观测存放在window[seq%container]
+	sum       float64
+	squareSum float64
+	maxQueue  monoQueue
+	minQueue  monoQueue
+}
+
+// monoQueue 单调队列, 按时间顺序保存窗口内观测的序号, 队首为窗口内的最大(最小)值
+type monoQueue struct {
+	seqs       []int
+	head, size int
+}
+
+func newMonoQueue(size int) monoQueue {
+	return monoQueue{seqs: make([]int, size)}
+}
+
+func (q *monoQueue) front() int {
+	return q.seqs[q.head]
+}
+
+func (q *monoQueue) back() int {
+	return q.seqs[(q.head+q.size-1)%len(q.seqs)]
+}
+
+func (q *monoQueue) popFront() {
+	q.head = (q.head + 1) % len(q.seqs)
+	q.size--
+}
+
+func (q *monoQueue) push(seq int, value float64, values []float64, before func(a, b float64) bool) {
+	for q.size > 0 && before(value, values[q.back()%len(values)]) {
+		q.size--
+	}
+	q.seqs[(q.head+q.size)%len(q.seqs)] = seq
+	q.size++
 }
 
 func NewSlidingWindow(size int) (*SlidingWindow, error) {
 	if size <= 0 {
 		return nil, errors.New("In NewSlidingWindow, the length invalid size <=0")
 	}
-	return &SlidingWindow{window: make([]float64, size), index: 0, count: 0, container: size}, nil
+	return &SlidingWindow{
+		window:    make([]float64, size),
+		index:     0,
+		count:     0,
+		container: size,
+		maxQueue:  newMonoQueue(size),
+		minQueue:  newMonoQueue(size),
+	}, nil
 }
 
+// Data 按时间顺序复制窗口中的数据, 每次调用都会分配内存, 遍历时应使用At或Range
 func (w *SlidingWindow) Data() []float64 {
 	ret := make([]float64, w.count)
-	index := w.index % w.count
+	for i := range ret {
+		ret[i] = w.At(i)
+	}
+	return ret
+}
+
+// At 窗口中按时间顺序的第i个观测, 0为最早的观测
+func (w *SlidingWindow) At(i int) float64 {
+	return w.window[(w.seq-w.count+i)%w.container]
+}
+
+// Range 按时间顺序遍历窗口, f返回false时停止, 不会分配内存
+func (w *SlidingWindow) Range(f func(i int, value float64) bool) {
 	for i := 0; i < w.count; i++ {
-		ret[i] = w.window[index]
-		index++
-		if index >= w.count {
-			index = 0
+		if !f(i, w.At(i)) {
+			return
 		}
 	}
-	return ret
 }
 
 func (w *SlidingWindow) Add(value float64) {
 	if math.IsNaN(value) {
 		value = 0.0
 	}
+	old := w.window[w.index]
+	if w.count == w.container {
+		w.sum -= old
+		w.squareSum -= old * old
+		if w.maxQueue.front() == w.seq-w.container {
+			w.maxQueue.popFront()
+		}
+		if w.minQueue.front() == w.seq-w.container {
+			w.minQueue.popFront()
+		}
+	}
 	w.window[w.index] = value
+	w.sum += value
+	w.squareSum += value * value
+	w.maxQueue.push(w.seq, value, w.window, func(a, b float64) bool { return a > b })
+	w.minQueue.push(w.seq, value, w.window, func(a, b float64) bool { return a < b })
+	w.seq++
 	w.index++
 	if w.index >= len(w.window) {
 		w.index = 0
 	}
 	w.count++
 	w.count = int(math.Min(float64(len(w.window)), float64(w.count)))
+	// 每转一圈或移出无穷值时重新求和, 避免累积的舍入误差
+	if w.index == 0 || math.IsInf(old, 0) {
+		w.resum()
+	}
 }
 
-func (w *SlidingWindow) Average() (average float64) {
-	average = 0.0
+func (w *SlidingWindow) resum() {
+	w.sum, w.squareSum = 0.0, 0.0
 	for i := 0; i < w.count; i++ {
-		average += w.window[i]
+		w.sum += w.window[i]
+		w.squareSum += w.window[i] * w.window[i]
 	}
-	average /= float64(w.count)
-	return
+}
+
+func (w *SlidingWindow) Average() (average float64) {
+	return w.sum / float64(w.count)
 }
 
 func (w *SlidingWindow) StdDev() (stddev float64) {
-	stddev = 0.0
 	ave := w.Average()
-	for i := 0; i < w.count; i++ {
-		stddev += w.window[i] * w.window[i]
+	stddev = w.squareSum/float64(w.count) - ave*ave
+	if stddev < 0 {
+		stddev = 0
 	}
-	stddev /= float64(w.count)
-	stddev -= ave * ave
-	stddev = math.Sqrt(stddev)
-	return
+	return math.Sqrt(stddev)
 }
 
 func (w *SlidingWindow) Sum() (total float64) {
-	total = 0.0
-	for i := 0; i < w.count; i++ {
-		total += w.window[i]
-	}
-	return
+	return w.sum
 }
 
+// Max 窗口内的最大值及其位置, 位置0为最早的观测, 相同的最大值取最早的一个
 func (w *SlidingWindow) Max() (value float64, position int) {
-	value = math.Inf(-1)
-	position = -1
-	for i := 0; i < w.count; i++ {
-		if w.window[i] > value {
-			value = w.window[i]
-			position = i
-		}
+	if w.count == 0 {
+		return math.Inf(-1), -1
 	}
-	position -= w.index
-	if position < 0 {
-		position += w.count
-	}
-	return
+	seq := w.maxQueue.front()
+	return w.window[seq%w.container], seq - (w.seq - w.count)
 }
 
+// Min 窗口内的最小值及其位置, 位置0为最早的观测, 相同的最小值取最早的一个
 func (w *SlidingWindow) Min() (value float64, position int) {
-	value = math.Inf(1)
-	position = -1
-	for i := 0; i < w.count; i++ {
-		if w.window[i] < value {
-			value = w.window[i]
-			position = i
-		}
-	}
-	position -= w.index
-	if position < 0 {
-		position += w.count
+	if w.count == 0 {
+		return math.Inf(1), -1
 	}
-	return
+	seq := w.minQueue.front()
+	return w.window[seq%w.container], seq - (w.seq - w.count)
 }
 
 func (w *SlidingWindow) Count() int {
@@ -130,7 +186,5 @@ func (w *SlidingWindow) RefData(ref int) (value float64) {
 }
 
 func (w *SlidingWindow) First() (value float64) {
-	index := w.index % w.count
-	value = w.window[index]
-	return
+	return w.At(0)
 }
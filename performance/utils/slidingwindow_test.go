@@ -64,3 +64,48 @@ func TestSlidingwindow(t *testing.T) {
 func round(f float64) int {
 	return int(math.Floor(f + 0.5))
 }
+
+func TestSlidingwindowRunning(t *testing.T) {
+	size := 7
+	test, _ := NewSlidingWindow(size)
+	var all []float64
+	for i := 0; i < 100; i++ {
+		v := float64(rand.Intn(10))
+		test.Add(v)
+		all = append(all, v)
+		data := all
+		if len(data) > size {
+			data = data[len(data)-size:]
+		}
+		sum, max, min, maxPos, minPos := 0.0, math.Inf(-1), math.Inf(1), -1, -1
+		for j, d := range data {
+			sum += d
+			if d > max {
+				max, maxPos = d, j
+			}
+			if d < min {
+				min, minPos = d, j
+			}
+			if test.At(j) != d {
+				t.Fatalf("bad At(%d): %f, expected %f", j, test.At(j), d)
+			}
+		}
+		if math.Abs(test.Sum()-sum) > 1e-9 || math.Abs(test.Average()-sum/float64(len(data))) > 1e-9 {
+			t.Fatalf("bad running sum: %f, expected %f", test.Sum(), sum)
+		}
+		if v, p := test.Max(); v != max || p != maxPos {
+			t.Fatalf("bad Max: %f, %d, expected %f, %d", v, p, max, maxPos)
+		}
+		if v, p := test.Min(); v != min || p != minPos {
+			t.Fatalf("bad Min: %f, %d, expected %f, %d", v, p, min, minPos)
+		}
+		count := 0
+		test.Range(func(j int, value float64) bool {
+			count++
+			return value == data[j]
+		})
+		if count != len(data) {
+			t.Fatalf("bad Range: %d values, expected %d", count, len(data))
+		}
+	}
+}